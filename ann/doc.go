@@ -0,0 +1,6 @@
+/*
+
+Package ann implements approximate nearest neighbor indices over latent factors.
+
+*/
+package ann
@@ -0,0 +1,339 @@
+package ann
+
+import (
+	"container/heap"
+	"github.com/zhenghaoz/gorse/core"
+	"gonum.org/v1/gonum/floats"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// Metric of similarity
+const (
+	Dot    = "dot"    // Inner product
+	Cosine = "cosine" // Cosine similarity
+)
+
+// HNSW: Hierarchical Navigable Small World graphs[1]. Vectors are inserted into
+// a hierarchy of proximity graphs, where the upper layers contain subsets of
+// vectors with long-range links. A query greedily descends the hierarchy and
+// searches the bottom layer with a dynamic candidate list. The similarity of a
+// pair of vectors is the inner product or the cosine similarity. An index could
+// be saved and loaded by core.Save() and core.Load().
+//
+// [1] Malkov, Yu A., and D. A. Yashunin. "Efficient and robust approximate
+// nearest neighbor search using Hierarchical Navigable Small World graphs."
+// IEEE transactions on pattern analysis and machine intelligence (2018).
+type HNSW struct {
+	Vectors    [][]float64 // Indexed vectors (normalized for cosine similarity)
+	Neighbors  [][][]int   // Neighbors of each vector in each layer
+	EntryPoint int         // The entry point in the top layer
+	MaxLevel   int         // The level of the top layer
+	Metric     string      // The metric of similarity
+	Ef         int         // The size of the dynamic candidate list in search
+	// Hyper parameters
+	maxNeighbors   int
+	efConstruction int
+	levelMult      float64
+	rng            *rand.Rand
+}
+
+// NewHNSW builds a HNSW index from vectors, such as the ItemFactor of a factor
+// model. The ID of a vector is its index in vectors. Parameters:
+//   metric         - The metric of similarity: "dot" or "cosine". Default is "dot".
+//   maxNeighbors   - The maximum number of neighbors of a vector in a upper layer. Vectors in
+//                    the bottom layer have at most 2*maxNeighbors neighbors. It must be at
+//                    least 2. Default is 16.
+//   efConstruction - The size of the dynamic candidate list in construction. Default is 200.
+//   ef             - The size of the dynamic candidate list in search. Default is 50.
+//   randState      - The random seed. Default is UNIX time step.
+func NewHNSW(vectors [][]float64, params core.Parameters) *HNSW {
+	index := new(HNSW)
+	index.Metric = params.GetString("metric", Dot)
+	index.Ef = params.GetInt("ef", 50)
+	index.maxNeighbors = params.GetInt("maxNeighbors", 16)
+	if index.maxNeighbors < 2 {
+		// The level multiplier 1/ln(maxNeighbors) is infinite or negative
+		panic("NewHNSW: maxNeighbors must be at least 2")
+	}
+	index.efConstruction = params.GetInt("efConstruction", 200)
+	index.levelMult = 1 / math.Log(float64(index.maxNeighbors))
+	randState := params.GetInt("randState", int(time.Now().UnixNano()))
+	index.rng = rand.New(rand.NewSource(int64(randState)))
+	// Insert vectors
+	index.EntryPoint = -1
+	index.Vectors = make([][]float64, 0, len(vectors))
+	index.Neighbors = make([][][]int, 0, len(vectors))
+	for _, vector := range vectors {
+		index.insert(vector)
+	}
+	return index
+}
+
+// Search finds the top k most similar vectors to the query. Return IDs and
+// similarities in descending order of similarity.
+func (index *HNSW) Search(query []float64, k int) ([]int, []float64) {
+	if index.EntryPoint < 0 {
+		return []int{}, []float64{}
+	}
+	query = index.prepare(query)
+	// Greedy search in upper layers
+	entryPoint := index.EntryPoint
+	for level := index.MaxLevel; level > 0; level-- {
+		entryPoint = index.searchLayer(query, []int{entryPoint}, 1, level)[0].id
+	}
+	// Search in the bottom layer
+	ef := index.Ef
+	if ef < k {
+		ef = k
+	}
+	candidates := index.searchLayer(query, []int{entryPoint}, ef, 0)
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	ids := make([]int, len(candidates))
+	sims := make([]float64, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.id
+		sims[i] = -c.dist
+	}
+	return ids, sims
+}
+
+// BruteForce finds the top k most similar vectors to the query by scanning all
+// vectors. Return IDs and similarities in descending order of similarity.
+func (index *HNSW) BruteForce(query []float64, k int) ([]int, []float64) {
+	query = index.prepare(query)
+	candidates := make([]candidate, len(index.Vectors))
+	for i := range index.Vectors {
+		candidates[i] = candidate{i, index.distance(query, i)}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].dist < candidates[j].dist
+	})
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	ids := make([]int, len(candidates))
+	sims := make([]float64, len(candidates))
+	for i, c := range candidates {
+		ids[i] = c.id
+		sims[i] = -c.dist
+	}
+	return ids, sims
+}
+
+// Recall computes the average fraction of the top k vectors found by brute
+// force that are also returned by Search() for the queries.
+func (index *HNSW) Recall(queries [][]float64, k int) float64 {
+	sum := 0.0
+	for _, query := range queries {
+		expect, _ := index.BruteForce(query, k)
+		actual, _ := index.Search(query, k)
+		found := make(map[int]bool)
+		for _, id := range actual {
+			found[id] = true
+		}
+		hit := 0.0
+		for _, id := range expect {
+			if found[id] {
+				hit++
+			}
+		}
+		sum += hit / float64(len(expect))
+	}
+	return sum / float64(len(queries))
+}
+
+// Insert a vector to the index.
+func (index *HNSW) insert(vector []float64) {
+	id := len(index.Vectors)
+	index.Vectors = append(index.Vectors, index.prepare(vector))
+	// Draw a random level: \lfloor -ln(unif(0..1)) \cdot m_L \rfloor
+	level := int(math.Floor(-math.Log(1-index.rng.Float64()) * index.levelMult))
+	index.Neighbors = append(index.Neighbors, make([][]int, level+1))
+	// The first vector
+	if index.EntryPoint < 0 {
+		index.EntryPoint = id
+		index.MaxLevel = level
+		return
+	}
+	// Greedy search in upper layers
+	entryPoints := []int{index.EntryPoint}
+	for l := index.MaxLevel; l > level; l-- {
+		entryPoints = []int{index.searchLayer(index.Vectors[id], entryPoints, 1, l)[0].id}
+	}
+	// Link neighbors in lower layers
+	for l := minInt(level, index.MaxLevel); l >= 0; l-- {
+		candidates := index.searchLayer(index.Vectors[id], entryPoints, index.efConstruction, l)
+		index.Neighbors[id][l] = index.selectNeighbors(candidates, index.maxNeighbors)
+		for _, neighbor := range index.Neighbors[id][l] {
+			index.Neighbors[neighbor][l] = append(index.Neighbors[neighbor][l], id)
+			// Shrink connections
+			if maxConn := index.maxConnections(l); len(index.Neighbors[neighbor][l]) > maxConn {
+				connections := make([]candidate, len(index.Neighbors[neighbor][l]))
+				for i, c := range index.Neighbors[neighbor][l] {
+					connections[i] = candidate{c, index.distance(index.Vectors[neighbor], c)}
+				}
+				sort.Slice(connections, func(i, j int) bool {
+					return connections[i].dist < connections[j].dist
+				})
+				index.Neighbors[neighbor][l] = index.selectNeighbors(connections, maxConn)
+			}
+		}
+		entryPoints = make([]int, len(candidates))
+		for i, c := range candidates {
+			entryPoints[i] = c.id
+		}
+	}
+	// Update the entry point
+	if level > index.MaxLevel {
+		index.EntryPoint = id
+		index.MaxLevel = level
+	}
+}
+
+// Search the nearest ef vectors in a layer. Return candidates sorted by distance.
+func (index *HNSW) searchLayer(query []float64, entryPoints []int, ef int, level int) []candidate {
+	visited := make(map[int]bool)
+	candidates := &candidateHeap{}
+	results := &candidateHeap{max: true}
+	for _, id := range entryPoints {
+		visited[id] = true
+		c := candidate{id, index.distance(query, id)}
+		heap.Push(candidates, c)
+		heap.Push(results, c)
+	}
+	for candidates.Len() > 0 {
+		nearest := heap.Pop(candidates).(candidate)
+		if nearest.dist > results.top().dist && results.Len() >= ef {
+			break
+		}
+		for _, neighbor := range index.Neighbors[nearest.id][level] {
+			if visited[neighbor] {
+				continue
+			}
+			visited[neighbor] = true
+			c := candidate{neighbor, index.distance(query, neighbor)}
+			if results.Len() < ef || c.dist < results.top().dist {
+				heap.Push(candidates, c)
+				heap.Push(results, c)
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+	// Sort results by distance
+	ret := make([]candidate, results.Len())
+	for i := len(ret) - 1; i >= 0; i-- {
+		ret[i] = heap.Pop(results).(candidate)
+	}
+	return ret
+}
+
+// Select neighbors by the heuristic which prefers diverse directions. Candidates
+// should be sorted by distance. Pruned candidates are used to fill the remaining.
+func (index *HNSW) selectNeighbors(candidates []candidate, m int) []int {
+	selected := make([]int, 0, m)
+	pruned := make([]int, 0, len(candidates))
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+		good := true
+		for _, s := range selected {
+			if index.distance(index.Vectors[c.id], s) < c.dist {
+				good = false
+				break
+			}
+		}
+		if good {
+			selected = append(selected, c.id)
+		} else {
+			pruned = append(pruned, c.id)
+		}
+	}
+	for i := 0; i < len(pruned) && len(selected) < m; i++ {
+		selected = append(selected, pruned[i])
+	}
+	return selected
+}
+
+// The maximum number of connections of a vector in a layer.
+func (index *HNSW) maxConnections(level int) int {
+	if level == 0 {
+		return 2 * index.maxNeighbors
+	}
+	return index.maxNeighbors
+}
+
+// Prepare a vector for the metric. A vector is normalized for cosine similarity.
+func (index *HNSW) prepare(vector []float64) []float64 {
+	ret := make([]float64, len(vector))
+	copy(ret, vector)
+	if index.Metric == Cosine {
+		if norm := floats.Norm(ret, 2); norm > 0 {
+			floats.Scale(1/norm, ret)
+		}
+	}
+	return ret
+}
+
+// The distance between a vector and an indexed vector, which is the negative similarity.
+func (index *HNSW) distance(vector []float64, id int) float64 {
+	return -floats.Dot(vector, index.Vectors[id])
+}
+
+/* Utils */
+
+// A candidate vector with its distance to the query.
+type candidate struct {
+	id   int
+	dist float64
+}
+
+// A heap of candidates. The nearest candidate is on the top by default and the
+// farthest candidate is on the top if max is true.
+type candidateHeap struct {
+	data []candidate
+	max  bool
+}
+
+func (h *candidateHeap) Len() int {
+	return len(h.data)
+}
+
+func (h *candidateHeap) Less(i, j int) bool {
+	if h.max {
+		return h.data[i].dist > h.data[j].dist
+	}
+	return h.data[i].dist < h.data[j].dist
+}
+
+func (h *candidateHeap) Swap(i, j int) {
+	h.data[i], h.data[j] = h.data[j], h.data[i]
+}
+
+func (h *candidateHeap) Push(x interface{}) {
+	h.data = append(h.data, x.(candidate))
+}
+
+func (h *candidateHeap) Pop() interface{} {
+	last := h.data[len(h.data)-1]
+	h.data = h.data[:len(h.data)-1]
+	return last
+}
+
+func (h *candidateHeap) top() candidate {
+	return h.data[0]
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
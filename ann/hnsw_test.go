@@ -0,0 +1,78 @@
+package ann
+
+import (
+	"github.com/zhenghaoz/gorse/core"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const recallThreshold = 0.9
+
+func newRandomVectors(n, dim int, seed int64) [][]float64 {
+	rng := rand.New(rand.NewSource(seed))
+	vectors := make([][]float64, n)
+	for i := range vectors {
+		vectors[i] = make([]float64, dim)
+		for j := range vectors[i] {
+			vectors[i][j] = rng.NormFloat64()
+		}
+	}
+	return vectors
+}
+
+func testRecall(t *testing.T, metric string) {
+	vectors := newRandomVectors(2000, 20, 0)
+	queries := newRandomVectors(100, 20, 1)
+	index := NewHNSW(vectors, core.Parameters{
+		"metric":    metric,
+		"randState": 0,
+	})
+	if recall := index.Recall(queries, 10); recall < recallThreshold {
+		t.Fatalf("Recall@10 %.3f < %.3f", recall, recallThreshold)
+	}
+}
+
+func TestHNSW_Dot(t *testing.T) {
+	testRecall(t, Dot)
+}
+
+func TestHNSW_Cosine(t *testing.T) {
+	testRecall(t, Cosine)
+}
+
+func TestHNSW_MaxNeighbors(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expect a panic with maxNeighbors < 2")
+		}
+	}()
+	NewHNSW(newRandomVectors(10, 2, 0), core.Parameters{"maxNeighbors": 1})
+}
+
+func TestHNSW_Save(t *testing.T) {
+	vectors := newRandomVectors(500, 10, 0)
+	query := newRandomVectors(1, 10, 1)[0]
+	index1 := NewHNSW(vectors, core.Parameters{"randState": 0})
+	ids1, _ := index1.Search(query, 10)
+	// Save the index
+	fileName := filepath.Join(os.TempDir(), "gorse", "hnsw.m")
+	if err := core.Save(fileName, index1); err != nil {
+		t.Fatal(err)
+	}
+	// Load the index
+	index2 := new(HNSW)
+	if err := core.Load(fileName, index2); err != nil {
+		t.Fatal(err)
+	}
+	ids2, _ := index2.Search(query, 10)
+	if len(ids1) != len(ids2) {
+		t.Fatalf("The index restored from the file returns %d results != %d", len(ids2), len(ids1))
+	}
+	for i := range ids1 {
+		if ids1[i] != ids2[i] {
+			t.Fatalf("The index restored from the file returns different results: %v != %v", ids2, ids1)
+		}
+	}
+}
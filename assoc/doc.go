@@ -0,0 +1,6 @@
+/*
+
+Package assoc implements association rule mining over ratings of users.
+
+*/
+package assoc
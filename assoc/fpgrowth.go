@@ -0,0 +1,236 @@
+package assoc
+
+import (
+	"github.com/zhenghaoz/gorse/core"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Itemset is a set of items and its support.
+type Itemset struct {
+	Items   []int   // Sorted items
+	Support float64 // The fraction of baskets containing all items
+}
+
+// Rule is an association rule X => Y.
+type Rule struct {
+	Antecedent []int   // X (sorted)
+	Consequent []int   // Y (sorted)
+	Support    float64 // supp(X ∪ Y)
+	Confidence float64 // supp(X ∪ Y) / supp(X)
+	Lift       float64 // supp(X ∪ Y) / (supp(X) supp(Y))
+}
+
+// Baskets converts ratings of users to baskets of item IDs. Only items rated
+// no less than the threshold are put into baskets.
+func Baskets(trainSet core.TrainSet, threshold float64) [][]int {
+	baskets := make([][]int, 0, trainSet.UserCount)
+	for _, irs := range trainSet.UserRatings() {
+		basket := make([]int, 0, len(irs))
+		for _, ir := range irs {
+			if ir.Rating >= threshold {
+				basket = append(basket, trainSet.OuterItemId(ir.Id))
+			}
+		}
+		baskets = append(baskets, basket)
+	}
+	return baskets
+}
+
+// FPGrowth mines frequent itemsets from baskets by the FP-Growth algorithm[1]. An
+// itemset is frequent if its support is no less than minSupport. Itemsets with more
+// than maxLength items are not mined. Baskets are compressed into a prefix tree of
+// items ordered by frequencies and frequent itemsets are grown from conditional
+// trees of suffixes without candidate generation.
+//
+// [1] Han, Jiawei, Jian Pei, and Yiwen Yin. "Mining frequent patterns without
+// candidate generation." ACM sigmod record. Vol. 29. No. 2. ACM, 2000.
+func FPGrowth(baskets [][]int, minSupport float64, maxLength int) []Itemset {
+	if len(baskets) == 0 {
+		return []Itemset{}
+	}
+	minCount := int(minSupport * float64(len(baskets)))
+	if float64(minCount) < minSupport*float64(len(baskets)) {
+		minCount++
+	}
+	if minCount < 1 {
+		minCount = 1
+	}
+	counts := make([]int, len(baskets))
+	for i := range counts {
+		counts[i] = 1
+	}
+	tree := newFPTree(baskets, counts, minCount)
+	itemsets := make([]Itemset, 0)
+	tree.mine(nil, minCount, maxLength, func(items []int, count int) {
+		itemset := Itemset{Items: make([]int, len(items)), Support: float64(count) / float64(len(baskets))}
+		copy(itemset.Items, items)
+		sort.Ints(itemset.Items)
+		itemsets = append(itemsets, itemset)
+	})
+	return itemsets
+}
+
+// Rules generates association rules from frequent itemsets. Rules with confidence
+// less than minConfidence are discarded. All subsets of frequent itemsets should be
+// frequent itemsets as well, which holds for results of FPGrowth.
+func Rules(itemsets []Itemset, minConfidence float64) []Rule {
+	supports := make(map[string]float64)
+	for _, itemset := range itemsets {
+		supports[key(itemset.Items)] = itemset.Support
+	}
+	rules := make([]Rule, 0)
+	for _, itemset := range itemsets {
+		n := len(itemset.Items)
+		// Enumerate non-empty proper subsets as antecedents
+		for mask := 1; mask < (1<<uint(n))-1; mask++ {
+			antecedent := make([]int, 0, n)
+			consequent := make([]int, 0, n)
+			for i, item := range itemset.Items {
+				if mask&(1<<uint(i)) != 0 {
+					antecedent = append(antecedent, item)
+				} else {
+					consequent = append(consequent, item)
+				}
+			}
+			antecedentSupport, exist := supports[key(antecedent)]
+			if !exist {
+				continue
+			}
+			consequentSupport, exist := supports[key(consequent)]
+			if !exist {
+				continue
+			}
+			confidence := itemset.Support / antecedentSupport
+			if confidence >= minConfidence {
+				rules = append(rules, Rule{
+					Antecedent: antecedent,
+					Consequent: consequent,
+					Support:    itemset.Support,
+					Confidence: confidence,
+					Lift:       confidence / consequentSupport,
+				})
+			}
+		}
+	}
+	return rules
+}
+
+// Generate the key of a sorted itemset.
+func key(items []int) string {
+	s := make([]string, len(items))
+	for i, item := range items {
+		s[i] = strconv.Itoa(item)
+	}
+	return strings.Join(s, ",")
+}
+
+/* FP-Tree */
+
+// Node of a FP-tree.
+type fpNode struct {
+	item     int
+	count    int
+	parent   *fpNode
+	children map[int]*fpNode
+	next     *fpNode // The next node of the same item
+}
+
+// FP-tree: a prefix tree of baskets where items are ordered by frequencies.
+type fpTree struct {
+	root   *fpNode
+	heads  map[int]*fpNode // Linked lists of nodes of each item
+	counts map[int]int     // The count of each item
+	order  []int           // Frequent items in ascending order of counts
+}
+
+// Build a FP-tree from weighted baskets. Infrequent items are removed.
+func newFPTree(baskets [][]int, weights []int, minCount int) *fpTree {
+	tree := &fpTree{
+		root:   &fpNode{item: -1, children: make(map[int]*fpNode)},
+		heads:  make(map[int]*fpNode),
+		counts: make(map[int]int),
+	}
+	// Count items
+	for i, basket := range baskets {
+		for _, item := range basket {
+			tree.counts[item] += weights[i]
+		}
+	}
+	for item, count := range tree.counts {
+		if count >= minCount {
+			tree.order = append(tree.order, item)
+		} else {
+			delete(tree.counts, item)
+		}
+	}
+	sort.Slice(tree.order, func(i, j int) bool {
+		ci, cj := tree.counts[tree.order[i]], tree.counts[tree.order[j]]
+		if ci != cj {
+			return ci < cj
+		}
+		return tree.order[i] > tree.order[j]
+	})
+	rank := make(map[int]int)
+	for i, item := range tree.order {
+		rank[item] = i
+	}
+	// Insert baskets with items in descending order of counts
+	for i, basket := range baskets {
+		path := make([]int, 0, len(basket))
+		for _, item := range basket {
+			if _, exist := rank[item]; exist {
+				path = append(path, item)
+			}
+		}
+		sort.Slice(path, func(a, b int) bool {
+			return rank[path[a]] > rank[path[b]]
+		})
+		tree.insert(path, weights[i])
+	}
+	return tree
+}
+
+// Insert a path into a FP-tree.
+func (tree *fpTree) insert(path []int, count int) {
+	node := tree.root
+	for _, item := range path {
+		child, exist := node.children[item]
+		if !exist {
+			child = &fpNode{item: item, parent: node, children: make(map[int]*fpNode)}
+			child.next = tree.heads[item]
+			tree.heads[item] = child
+			node.children[item] = child
+		}
+		child.count += count
+		node = child
+	}
+}
+
+// Mine frequent itemsets with a suffix from a (conditional) FP-tree.
+func (tree *fpTree) mine(suffix []int, minCount, maxLength int, emit func(items []int, count int)) {
+	for _, item := range tree.order {
+		itemset := append(append(make([]int, 0, len(suffix)+1), suffix...), item)
+		emit(itemset, tree.counts[item])
+		if len(itemset) >= maxLength {
+			continue
+		}
+		// Build the conditional pattern base of the item
+		paths := make([][]int, 0)
+		weights := make([]int, 0)
+		for node := tree.heads[item]; node != nil; node = node.next {
+			path := make([]int, 0)
+			for parent := node.parent; parent != tree.root; parent = parent.parent {
+				path = append(path, parent.item)
+			}
+			if len(path) > 0 {
+				paths = append(paths, path)
+				weights = append(weights, node.count)
+			}
+		}
+		if len(paths) > 0 {
+			newFPTree(paths, weights, minCount).mine(itemset, minCount, maxLength, emit)
+		}
+	}
+}
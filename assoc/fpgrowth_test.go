@@ -0,0 +1,119 @@
+package assoc
+
+import (
+	"github.com/zhenghaoz/gorse/core"
+	"gonum.org/v1/gonum/stat"
+	"math"
+	"runtime"
+	"testing"
+)
+
+const epsilon = 1e-9
+
+// The example in the paper of FP-Growth with items renamed:
+// f=0, c=1, a=2, b=3, m=4, p=5, others are 6~13.
+var baskets = [][]int{
+	{0, 2, 1, 6, 7, 4, 5},
+	{2, 3, 1, 0, 8, 4, 9},
+	{3, 0, 10, 11, 9},
+	{3, 1, 12, 13, 5},
+	{2, 0, 1, 8, 5, 4, 7},
+}
+
+// Count supports of itemsets by brute force.
+func bruteForce(baskets [][]int, items []int) float64 {
+	count := 0
+	for _, basket := range baskets {
+		set := make(map[int]bool)
+		for _, item := range basket {
+			set[item] = true
+		}
+		all := true
+		for _, item := range items {
+			all = all && set[item]
+		}
+		if all {
+			count++
+		}
+	}
+	return float64(count) / float64(len(baskets))
+}
+
+func TestFPGrowth(t *testing.T) {
+	itemsets := FPGrowth(baskets, 0.6, 10)
+	// Frequent items: f:4, c:4, a:3, b:3, m:3, p:3. Frequent itemsets in the paper are:
+	// f, c, a, b, m, p, fc, fa, ca, fca, fm, cm, am, fcm, fam, cam, fcam, cp
+	if len(itemsets) != 18 {
+		t.Fatalf("Number of frequent itemsets (%d) != 18", len(itemsets))
+	}
+	for _, itemset := range itemsets {
+		if support := bruteForce(baskets, itemset.Items); math.Abs(support-itemset.Support) > epsilon {
+			t.Fatalf("Support of %v (%f) != %f", itemset.Items, itemset.Support, support)
+		}
+	}
+	// Limit the length of itemsets
+	for _, itemset := range FPGrowth(baskets, 0.6, 2) {
+		if len(itemset.Items) > 2 {
+			t.Fatalf("Length of %v > 2", itemset.Items)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	rules := Rules(FPGrowth(baskets, 0.6, 10), 0.8)
+	if len(rules) == 0 {
+		t.Fatal("No rule is mined")
+	}
+	for _, rule := range rules {
+		all := append(append([]int{}, rule.Antecedent...), rule.Consequent...)
+		support := bruteForce(baskets, all)
+		confidence := support / bruteForce(baskets, rule.Antecedent)
+		lift := confidence / bruteForce(baskets, rule.Consequent)
+		if math.Abs(support-rule.Support) > epsilon ||
+			math.Abs(confidence-rule.Confidence) > epsilon ||
+			math.Abs(lift-rule.Lift) > epsilon {
+			t.Fatalf("Rule %v => %v (%f, %f, %f) != (%f, %f, %f)", rule.Antecedent, rule.Consequent,
+				rule.Support, rule.Confidence, rule.Lift, support, confidence, lift)
+		}
+		if rule.Confidence < 0.8 {
+			t.Fatalf("Confidence of %v => %v (%f) < 0.8", rule.Antecedent, rule.Consequent, rule.Confidence)
+		}
+	}
+}
+
+func TestRuleBased(t *testing.T) {
+	dataSet := core.LoadDataFromBuiltIn("ml-100k")
+	results := core.CrossValidate(NewRuleBased(nil), dataSet, []core.Evaluator{core.NewAUCEvaluator(dataSet)},
+		core.NewUserLOOSplitter(1), 0, core.Parameters{"randState": 0, "minSupport": 0.05}, runtime.NumCPU())
+	if auc := stat.Mean(results[0].Tests, nil); auc < 0.58 {
+		t.Fatalf("AUC(%.3f) < 0.58", auc)
+	}
+}
+
+func TestRuleBased_Explain(t *testing.T) {
+	users, items := []int{}, []int{}
+	for u, basket := range baskets {
+		for _, item := range basket {
+			users = append(users, u)
+			items = append(items, item)
+		}
+	}
+	ratings := make([]float64, len(users))
+	rb := NewRuleBased(core.Parameters{"minSupport": 0.6, "minConfidence": 0.8})
+	rb.Fit(core.NewTrainSet(core.NewRawDataSet(users, items, ratings)))
+	// User 3 rated c (1) and p (5), where p => c has confidence 1.
+	if rules := rb.Explain(3, 1); len(rules) == 0 {
+		t.Fatal("No rule is fired")
+	}
+	if score := rb.Predict(3, 1); math.Abs(score-1) > epsilon {
+		t.Fatalf("Predict(3, 1) = %f != 1", score)
+	}
+	// Only the empty rule is fired for new users
+	if score := rb.Predict(100, 1); math.Abs(score-0.8) > epsilon {
+		t.Fatalf("Predict(100, 1) = %f != 0.8", score)
+	}
+	// Infrequent items
+	if score := rb.Predict(3, 6); score != 0 {
+		t.Fatalf("Predict(3, 6) = %f != 0", score)
+	}
+}
@@ -0,0 +1,151 @@
+package assoc
+
+import (
+	"github.com/zhenghaoz/gorse/core"
+	"sort"
+)
+
+// Score of fired rules
+const (
+	Confidence = "confidence"
+	Lift       = "lift"
+)
+
+// RuleBased recommends items by association rules mined from ratings of users,
+// which are treated as baskets. Rules X => {i} whose antecedent X is contained
+// in the history of user u are fired and the prediction for item i is:
+//
+//   \hat{r}_{ui} = \max_{X \subseteq H_u, X => \{i\}} score(X => \{i\})
+//
+// where the score is the confidence or the lift of a rule. The empty rule {} => {i}
+// is always fired, whose confidence is the support of item i and lift is 1. The
+// prediction is zero for infrequent items. Rules are interpretable as "users who
+// liked X also liked i" and could be retrieved by Explain().
+type RuleBased struct {
+	core.Base
+	Rules        []Rule          // Mined association rules
+	ItemRules    map[int][]int   // itemId -> indices of rules whose consequent is {itemId}, sorted by scores
+	ItemSupports map[int]float64 // itemId -> supports of frequent items
+	Histories    [][]int         // Sorted items in baskets of users
+	// Hyper parameters
+	minSupport    float64
+	minConfidence float64
+	maxLength     int
+	threshold     float64
+	score         string
+}
+
+// NewRuleBased creates a association rule based recommender. Parameters:
+//   minSupport    - The minimum support of frequent itemsets. Default is 0.01.
+//   minConfidence - The minimum confidence of rules. Default is 0.1.
+//   maxLength     - The maximum number of items in frequent itemsets. Default is 3.
+//   threshold     - Items rated no less than the threshold are put into baskets. Default is 0.
+//   score         - The score of a fired rule: "confidence" or "lift". Default is "confidence".
+func NewRuleBased(params core.Parameters) *RuleBased {
+	rb := new(RuleBased)
+	rb.SetParams(params)
+	return rb
+}
+
+// SetParams sets hyper parameters.
+func (rb *RuleBased) SetParams(params core.Parameters) {
+	rb.Base.SetParams(params)
+	rb.minSupport = rb.Params.GetFloat64("minSupport", 0.01)
+	rb.minConfidence = rb.Params.GetFloat64("minConfidence", 0.1)
+	rb.maxLength = rb.Params.GetInt("maxLength", 3)
+	rb.threshold = rb.Params.GetFloat64("threshold", 0)
+	rb.score = rb.Params.GetString("score", Confidence)
+}
+
+// Predict by a rule based recommender.
+func (rb *RuleBased) Predict(userId, itemId int) float64 {
+	// The empty rule {} => {i}
+	support, exist := rb.ItemSupports[itemId]
+	if !exist {
+		return 0
+	}
+	ret := support
+	if rb.score == Lift {
+		ret = 1
+	}
+	innerUserId := rb.Data.ConvertUserId(userId)
+	if innerUserId == core.NewId {
+		return ret
+	}
+	// Rules are sorted in descending order of scores
+	for _, r := range rb.ItemRules[itemId] {
+		if contains(rb.Histories[innerUserId], rb.Rules[r].Antecedent) {
+			if score := rb.scoreOf(r); score > ret {
+				ret = score
+			}
+			break
+		}
+	}
+	return ret
+}
+
+// The score of a rule.
+func (rb *RuleBased) scoreOf(r int) float64 {
+	if rb.score == Lift {
+		return rb.Rules[r].Lift
+	}
+	return rb.Rules[r].Confidence
+}
+
+// Explain returns rules fired to recommend an item to a user in descending
+// order of scores. The empty rule isn't included.
+func (rb *RuleBased) Explain(userId, itemId int) []Rule {
+	fired := make([]Rule, 0)
+	innerUserId := rb.Data.ConvertUserId(userId)
+	if innerUserId == core.NewId {
+		return fired
+	}
+	for _, r := range rb.ItemRules[itemId] {
+		if contains(rb.Histories[innerUserId], rb.Rules[r].Antecedent) {
+			fired = append(fired, rb.Rules[r])
+		}
+	}
+	return fired
+}
+
+// Fit a rule based recommender.
+func (rb *RuleBased) Fit(trainSet core.TrainSet) {
+	rb.Base.Fit(trainSet)
+	rb.Histories = Baskets(trainSet, rb.threshold)
+	for _, history := range rb.Histories {
+		sort.Ints(history)
+	}
+	// Mine rules
+	itemsets := FPGrowth(rb.Histories, rb.minSupport, rb.maxLength)
+	rb.Rules = Rules(itemsets, rb.minConfidence)
+	rb.ItemSupports = make(map[int]float64)
+	for _, itemset := range itemsets {
+		if len(itemset.Items) == 1 {
+			rb.ItemSupports[itemset.Items[0]] = itemset.Support
+		}
+	}
+	// Index rules with single consequents in descending order of scores
+	rb.ItemRules = make(map[int][]int)
+	for r, rule := range rb.Rules {
+		if len(rule.Consequent) == 1 {
+			item := rule.Consequent[0]
+			rb.ItemRules[item] = append(rb.ItemRules[item], r)
+		}
+	}
+	for _, rules := range rb.ItemRules {
+		sort.Slice(rules, func(i, j int) bool {
+			return rb.scoreOf(rules[i]) > rb.scoreOf(rules[j])
+		})
+	}
+}
+
+// Check whether a sorted set contains all items of a subset.
+func contains(set, subset []int) bool {
+	for _, item := range subset {
+		i := sort.SearchInts(set, item)
+		if i == len(set) || set[i] != item {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package bandit
+
+import (
+	"github.com/zhenghaoz/gorse/core"
+	"gonum.org/v1/gonum/floats"
+	"math/rand"
+	"testing"
+)
+
+// A simulated environment where the expected reward of an arm is θ^Tx.
+type environment struct {
+	theta []float64
+	arms  [][]float64
+	rng   *rand.Rand
+}
+
+func newEnvironment(nArms, dim int, seed int64) *environment {
+	env := &environment{rng: rand.New(rand.NewSource(seed))}
+	env.theta = make([]float64, dim)
+	for i := range env.theta {
+		env.theta[i] = env.rng.NormFloat64()
+	}
+	env.arms = make([][]float64, nArms)
+	for a := range env.arms {
+		env.arms[a] = make([]float64, dim)
+		for i := range env.arms[a] {
+			env.arms[a][i] = env.rng.NormFloat64()
+		}
+	}
+	return env
+}
+
+func (env *environment) reward(arm int) float64 {
+	return floats.Dot(env.theta, env.arms[arm]) + 0.1*env.rng.NormFloat64()
+}
+
+func (env *environment) best() int {
+	best := 0
+	for a := range env.arms {
+		if floats.Dot(env.theta, env.arms[a]) > floats.Dot(env.theta, env.arms[best]) {
+			best = a
+		}
+	}
+	return best
+}
+
+// Run a bandit in a simulated environment and return chosen arms.
+func simulate(bandit Bandit, env *environment, nRounds int) []int {
+	chosen := make([]int, nRounds)
+	for t := range chosen {
+		chosen[t] = bandit.Choose(env.arms)
+		bandit.Update(env.arms[chosen[t]], env.reward(chosen[t]))
+	}
+	return chosen
+}
+
+func testConverge(t *testing.T, bandit Bandit) {
+	env := newEnvironment(20, 5, 0)
+	chosen := simulate(bandit, env, 1000)
+	best, count := env.best(), 0
+	for _, arm := range chosen[900:] {
+		if arm == best {
+			count++
+		}
+	}
+	if count < 90 {
+		t.Fatalf("The best arm is chosen %d < 90 times in the last 100 rounds", count)
+	}
+}
+
+func testSeed(t *testing.T, newBandit func(params core.Parameters) Bandit) {
+	chosen1 := simulate(newBandit(core.Parameters{"randState": 0}), newEnvironment(20, 5, 0), 100)
+	chosen2 := simulate(newBandit(core.Parameters{"randState": 0}), newEnvironment(20, 5, 0), 100)
+	for i := range chosen1 {
+		if chosen1[i] != chosen2[i] {
+			t.Fatalf("Bandits with the same seed choose %d != %d in round %d", chosen1[i], chosen2[i], i)
+		}
+	}
+}
+
+func TestLinUCB(t *testing.T) {
+	testConverge(t, NewLinUCB(5, core.Parameters{"randState": 0}))
+	testSeed(t, func(params core.Parameters) Bandit {
+		return NewLinUCB(5, params)
+	})
+}
+
+func TestThompsonSampling(t *testing.T) {
+	testConverge(t, NewThompsonSampling(5, core.Parameters{"randState": 0, "v": 0.1}))
+	testSeed(t, func(params core.Parameters) Bandit {
+		return NewThompsonSampling(5, params)
+	})
+}
+
+func TestReplay(t *testing.T) {
+	// Log events by a uniformly random policy
+	env := newEnvironment(10, 5, 0)
+	events := make([]Event, 20000)
+	average := 0.0
+	for i := range events {
+		chosen := env.rng.Intn(len(env.arms))
+		events[i] = Event{Arms: env.arms, Chosen: chosen, Reward: env.reward(chosen)}
+		average += events[i].Reward / float64(len(events))
+	}
+	reward, count := Replay(NewLinUCB(5, core.Parameters{"randState": 0}), events)
+	if count == 0 {
+		t.Fatal("No event is kept")
+	}
+	if reward <= average {
+		t.Fatalf("The average reward of LinUCB (%f) <= that of the random policy (%f)", reward, average)
+	}
+}
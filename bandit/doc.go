@@ -0,0 +1,6 @@
+/*
+
+Package bandit implements contextual bandits to explore and exploit candidate items online.
+
+*/
+package bandit
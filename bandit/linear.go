@@ -0,0 +1,188 @@
+package bandit
+
+import (
+	"github.com/zhenghaoz/gorse/core"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Bandit chooses an arm among candidates and learns from rewards. An arm is
+// represented by a feature vector, such as the ItemFactor of an item in a
+// factor model or side features of an item.
+type Bandit interface {
+	// Choose returns the index of the chosen arm among feature vectors of candidates.
+	Choose(arms [][]float64) int
+	// Update learns from the reward of a chosen arm.
+	Update(arm []float64, reward float64)
+}
+
+// Linear is a ridge regression from features of arms to expected rewards, which
+// is shared by linear bandits:
+//
+//   A = λI + \sum_t x_tx_t^T, b = \sum_t r_tx_t, \hat{θ} = A^{-1}b
+//
+// A^{-1} is updated by the Sherman-Morrison formula in O(d^2) time.
+type Linear struct {
+	AInv [][]float64 // A^{-1}
+	B    []float64   // b
+	// Random generator
+	rng *rand.Rand
+}
+
+// Create a linear model. Parameters:
+//   reg       - The regularization parameter λ. Default is 1.
+//   randState - The random seed. Default is UNIX time step.
+func newLinear(dim int, params core.Parameters) Linear {
+	reg := params.GetFloat64("reg", 1)
+	randState := params.GetInt("randState", int(time.Now().UnixNano()))
+	linear := Linear{
+		AInv: make([][]float64, dim),
+		B:    make([]float64, dim),
+		rng:  rand.New(rand.NewSource(int64(randState))),
+	}
+	for i := range linear.AInv {
+		linear.AInv[i] = make([]float64, dim)
+		linear.AInv[i][i] = 1 / reg
+	}
+	return linear
+}
+
+// Theta returns the estimated coefficients \hat{θ} = A^{-1}b.
+func (linear *Linear) Theta() []float64 {
+	theta := make([]float64, len(linear.B))
+	for i := range theta {
+		theta[i] = floats.Dot(linear.AInv[i], linear.B)
+	}
+	return theta
+}
+
+// Update the model by a chosen arm and its reward.
+func (linear *Linear) Update(arm []float64, reward float64) {
+	// A^{-1} = A^{-1} - \frac{A^{-1}xx^TA^{-1}}{1 + x^TA^{-1}x}
+	ax := linear.mulVec(arm)
+	scale := 1 + floats.Dot(arm, ax)
+	for i := range linear.AInv {
+		floats.AddScaled(linear.AInv[i], -ax[i]/scale, ax)
+	}
+	// b = b + rx
+	floats.AddScaled(linear.B, reward, arm)
+}
+
+// Compute A^{-1}x.
+func (linear *Linear) mulVec(x []float64) []float64 {
+	ret := make([]float64, len(x))
+	for i := range ret {
+		ret[i] = floats.Dot(linear.AInv[i], x)
+	}
+	return ret
+}
+
+// Choose the arm with the highest score. Ties are broken randomly.
+func (linear *Linear) argMax(arms [][]float64, score func(arm []float64) float64) int {
+	best, bestScore, nTies := -1, math.Inf(-1), 0
+	for a, arm := range arms {
+		s := score(arm)
+		if s > bestScore {
+			best, bestScore, nTies = a, s, 1
+		} else if s == bestScore {
+			// Reservoir sampling among ties
+			nTies++
+			if linear.rng.Intn(nTies) == 0 {
+				best = a
+			}
+		}
+	}
+	return best
+}
+
+/* LinUCB */
+
+// LinUCB chooses the arm with the highest upper confidence bound[1]:
+//
+//   \hat{θ}^Tx + α \sqrt{x^TA^{-1}x}
+//
+// [1] Li, Lihong, et al. "A contextual-bandit approach to personalized news
+// article recommendation." Proceedings of the 19th international conference
+// on World wide web. ACM, 2010.
+type LinUCB struct {
+	Linear
+	Alpha float64 // α
+}
+
+// NewLinUCB creates a LinUCB bandit over arms with dim features. Parameters:
+//   alpha     - The width of the confidence bound. Default is 1.
+//   reg       - The regularization parameter λ. Default is 1.
+//   randState - The random seed. Default is UNIX time step.
+func NewLinUCB(dim int, params core.Parameters) *LinUCB {
+	return &LinUCB{
+		Linear: newLinear(dim, params),
+		Alpha:  params.GetFloat64("alpha", 1),
+	}
+}
+
+// Choose an arm by LinUCB.
+func (ucb *LinUCB) Choose(arms [][]float64) int {
+	theta := ucb.Theta()
+	return ucb.argMax(arms, func(arm []float64) float64 {
+		return floats.Dot(theta, arm) + ucb.Alpha*math.Sqrt(floats.Dot(arm, ucb.mulVec(arm)))
+	})
+}
+
+/* Thompson Sampling */
+
+// ThompsonSampling samples coefficients from the posterior and chooses the arm
+// with the highest sampled reward[2]:
+//
+//   \tilde{θ} \sim N(\hat{θ}, v^2A^{-1}), arm = \arg\max_x \tilde{θ}^Tx
+//
+// [2] Agrawal, Shipra, and Navin Goyal. "Thompson sampling for contextual bandits
+// with linear payoffs." International Conference on Machine Learning. 2013.
+type ThompsonSampling struct {
+	Linear
+	V float64 // v
+}
+
+// NewThompsonSampling creates a Thompson sampling bandit over arms with dim features.
+// Parameters:
+//   v         - The scale of the posterior. Default is 1.
+//   reg       - The regularization parameter λ. Default is 1.
+//   randState - The random seed. Default is UNIX time step.
+func NewThompsonSampling(dim int, params core.Parameters) *ThompsonSampling {
+	return &ThompsonSampling{
+		Linear: newLinear(dim, params),
+		V:      params.GetFloat64("v", 1),
+	}
+}
+
+// Choose an arm by Thompson sampling.
+func (ts *ThompsonSampling) Choose(arms [][]float64) int {
+	dim := len(ts.B)
+	// \tilde{θ} = \hat{θ} + vLz, where LL^T = A^{-1} and z \sim N(0, I)
+	theta := ts.Theta()
+	cov := mat.NewSymDense(dim, nil)
+	for i := 0; i < dim; i++ {
+		for j := i; j < dim; j++ {
+			cov.SetSym(i, j, (ts.AInv[i][j]+ts.AInv[j][i])/2)
+		}
+	}
+	var chol mat.Cholesky
+	if chol.Factorize(cov) {
+		z := mat.NewVecDense(dim, nil)
+		for i := 0; i < dim; i++ {
+			z.SetVec(i, ts.rng.NormFloat64())
+		}
+		var l mat.TriDense
+		chol.LTo(&l)
+		noise := mat.NewVecDense(dim, nil)
+		noise.MulVec(&l, z)
+		for i := range theta {
+			theta[i] += ts.V * noise.AtVec(i)
+		}
+	}
+	return ts.argMax(arms, func(arm []float64) float64 {
+		return floats.Dot(theta, arm)
+	})
+}
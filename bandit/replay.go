@@ -0,0 +1,33 @@
+package bandit
+
+// Event is a logged impression: the candidate arms, the arm chosen by the logging
+// policy and the observed reward.
+type Event struct {
+	Arms   [][]float64 // Feature vectors of candidates
+	Chosen int         // The index of the logged arm
+	Reward float64     // The reward of the logged arm
+}
+
+// Replay evaluates a bandit offline on logged events[1]. For each event, the bandit
+// chooses an arm among candidates. If the arm matches the logged one, the event is
+// kept and the bandit learns from its reward. Otherwise, the event is discarded.
+// Return the average reward and the number of kept events. The estimate is unbiased
+// if the logging policy chose arms uniformly at random.
+//
+// [1] Li, Lihong, et al. "Unbiased offline evaluation of contextual-bandit-based news
+// article recommendation algorithms." Proceedings of the fourth ACM international
+// conference on Web search and data mining. ACM, 2011.
+func Replay(bandit Bandit, events []Event) (float64, int) {
+	sum, count := 0.0, 0
+	for _, event := range events {
+		if bandit.Choose(event.Arms) == event.Chosen {
+			bandit.Update(event.Arms[event.Chosen], event.Reward)
+			sum += event.Reward
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, 0
+	}
+	return sum / float64(count), count
+}
@@ -0,0 +1,230 @@
+package core
+
+import (
+	"fmt"
+	"gonum.org/v1/gonum/mat"
+)
+
+// AutoRec: Autoencoders meet collaborative filtering [8]. In item-based
+// AutoRec, each item i is represented by its partially observed vector of
+// ratings r^{(i)}, which is reconstructed by
+//
+//   h(r; θ) = f(W·g(Vr + μ) + b)
+//
+// where g is the sigmoid function and f is the identity function. Only
+// observed ratings contribute to the reconstruction loss. Ratings are centered
+// by the global mean before being encoded, so missing entries of r are neutral
+// instead of pulling reconstructions toward zero. The user-based AutoRec
+// reconstructs user vectors instead.
+type AutoRec struct {
+	Base
+	// Model parameters
+	Encoder     *mat.Dense  // V
+	Decoder     *mat.Dense  // W
+	EncoderBias []float64   // μ
+	DecoderBias []float64   // b
+	Codes       [][]float64 // g(Vr + μ) of each user (item)
+	GlobalMean  float64
+	// Hyper parameters
+	bias       bool
+	batchSize  int
+	nFactors   int
+	nEpochs    int
+	lr         float64
+	reg        float64
+	initMean   float64
+	initStdDev float64
+	userBased  bool
+	verbose    bool
+}
+
+// NewAutoRec creates an AutoRec model. Parameters:
+//   bias       - Add bias in encoder and decoder. Default is true.
+//   batchSize  - The number of vectors in a mini-batch. Default is 500.
+//	 reg 		- The regularization parameter of the cost function that is
+// 				  optimized. Default is 0.05.
+//	 lr 		- The learning rate of mini-batch gradient descent. Default is 1e-3.
+//	 nFactors	- The number of hidden units. Default is 50.
+//	 nEpochs	- The number of iteration of the mini-batch procedure. Default is 50.
+//	 initMean	- The mean of initial random weights. Default is 0.
+//	 initStdDev	- The standard deviation of initial random weights. Default is 0.03.
+//   userBased  - User based or item based? Default is false.
+//   verbose    - Print the loss of each epoch. Default is false.
+func NewAutoRec(params Parameters) *AutoRec {
+	auto := new(AutoRec)
+	auto.SetParams(params)
+	return auto
+}
+
+// SetParams sets hyper parameters.
+func (auto *AutoRec) SetParams(params Parameters) {
+	auto.Base.SetParams(params)
+	auto.bias = auto.Params.GetBool("bias", true)
+	auto.batchSize = auto.Params.GetInt("batchSize", 500)
+	auto.nFactors = auto.Params.GetInt("nFactors", 50)
+	auto.nEpochs = auto.Params.GetInt("nEpochs", 50)
+	auto.lr = auto.Params.GetFloat64("lr", 1e-3)
+	auto.reg = auto.Params.GetFloat64("reg", 0.05)
+	auto.initMean = auto.Params.GetFloat64("initMean", 0)
+	auto.initStdDev = auto.Params.GetFloat64("initStdDev", 0.03)
+	auto.userBased = auto.Params.GetBool("userBased", false)
+	auto.verbose = auto.Params.GetBool("verbose", false)
+}
+
+// Predict by an AutoRec model.
+func (auto *AutoRec) Predict(userId, itemId int) float64 {
+	innerUserId := auto.Data.ConvertUserId(userId)
+	innerItemId := auto.Data.ConvertItemId(itemId)
+	// Set user based or item based
+	var codeId, outputId int
+	if auto.userBased {
+		codeId, outputId = innerUserId, innerItemId
+	} else {
+		codeId, outputId = innerItemId, innerUserId
+	}
+	if codeId == NewId || outputId == NewId {
+		return auto.GlobalMean
+	}
+	// \bar{r} + W_o·h + b_o
+	ret := auto.GlobalMean + mat.Dot(auto.Decoder.RowView(outputId), mat.NewVecDense(auto.nFactors, auto.Codes[codeId]))
+	if auto.bias {
+		ret += auto.DecoderBias[outputId]
+	}
+	return ret
+}
+
+// Fit an AutoRec model.
+func (auto *AutoRec) Fit(trainSet TrainSet) {
+	auto.Base.Fit(trainSet)
+	auto.GlobalMean = trainSet.GlobalMean
+	// Retrieve input vectors
+	var vectors [][]IdRating
+	var nInputs int
+	if auto.userBased {
+		vectors = trainSet.UserRatings()
+		nInputs = trainSet.ItemCount
+	} else {
+		vectors = trainSet.ItemRatings()
+		nInputs = trainSet.UserCount
+	}
+	// Initialize parameters
+	auto.Encoder = mat.NewDense(auto.nFactors, nInputs, concatenateFloat(
+		auto.newNormalMatrix(auto.nFactors, nInputs, auto.initMean, auto.initStdDev)))
+	auto.Decoder = mat.NewDense(nInputs, auto.nFactors, concatenateFloat(
+		auto.newNormalMatrix(nInputs, auto.nFactors, auto.initMean, auto.initStdDev)))
+	auto.EncoderBias = make([]float64, auto.nFactors)
+	auto.DecoderBias = make([]float64, nInputs)
+	// Mini-batch gradient descent
+	for epoch := 0; epoch < auto.nEpochs; epoch++ {
+		perm := auto.rng.Perm(len(vectors))
+		loss := 0.0
+		for begin := 0; begin < len(perm); begin += auto.batchSize {
+			end := begin + auto.batchSize
+			if end > len(perm) {
+				end = len(perm)
+			}
+			batch := perm[begin:end]
+			// Create centered input matrix and mask matrix
+			input := mat.NewDense(nInputs, len(batch), nil)
+			mask := mat.NewDense(nInputs, len(batch), nil)
+			for j, id := range batch {
+				for _, ir := range vectors[id] {
+					input.Set(ir.Id, j, ir.Rating-auto.GlobalMean)
+					mask.Set(ir.Id, j, 1)
+				}
+			}
+			// Forward: H = g(VR + μ), Y = WH + b
+			hidden := auto.encode(input)
+			output := mat.NewDense(nInputs, len(batch), nil)
+			output.Mul(auto.Decoder, hidden)
+			if auto.bias {
+				addBias(output, auto.DecoderBias)
+			}
+			// E = (Y - R) ⊙ M
+			diff := mat.NewDense(nInputs, len(batch), nil)
+			diff.Sub(output, input)
+			diff.MulElem(diff, mask)
+			loss += mat.Sum(squareElem(diff))
+			// Backward: ∂W = EH^T + λW
+			gradDecoder := mat.NewDense(nInputs, auto.nFactors, nil)
+			gradDecoder.Mul(diff, hidden.T())
+			// ∂Z = (W^TE) ⊙ H ⊙ (1 - H)
+			gradHidden := mat.NewDense(auto.nFactors, len(batch), nil)
+			gradHidden.Mul(auto.Decoder.T(), diff)
+			gradHidden.Apply(func(i, j int, v float64) float64 {
+				h := hidden.At(i, j)
+				return v * h * (1 - h)
+			}, gradHidden)
+			// ∂V = ∂Z R^T + λV
+			gradEncoder := mat.NewDense(auto.nFactors, nInputs, nil)
+			gradEncoder.Mul(gradHidden, input.T())
+			// Update biases
+			if auto.bias {
+				updateBias(auto.DecoderBias, diff, auto.lr)
+				updateBias(auto.EncoderBias, gradHidden, auto.lr)
+			}
+			// Update weights
+			regularize(gradDecoder, auto.Decoder, auto.reg)
+			regularize(gradEncoder, auto.Encoder, auto.reg)
+			gradDecoder.Scale(auto.lr, gradDecoder)
+			gradEncoder.Scale(auto.lr, gradEncoder)
+			auto.Decoder.Sub(auto.Decoder, gradDecoder)
+			auto.Encoder.Sub(auto.Encoder, gradEncoder)
+		}
+		if auto.verbose {
+			fmt.Printf("Epoch %d/%d: loss = %f\n", epoch+1, auto.nEpochs, loss)
+		}
+	}
+	// Cache codes
+	auto.Codes = make([][]float64, len(vectors))
+	for id := range vectors {
+		input := mat.NewDense(nInputs, 1, nil)
+		for _, ir := range vectors[id] {
+			input.Set(ir.Id, 0, ir.Rating-auto.GlobalMean)
+		}
+		auto.Codes[id] = mat.Col(nil, 0, auto.encode(input))
+	}
+}
+
+// Encode input vectors (columns) to hidden vectors (columns).
+func (auto *AutoRec) encode(input *mat.Dense) *mat.Dense {
+	_, c := input.Dims()
+	hidden := mat.NewDense(auto.nFactors, c, nil)
+	hidden.Mul(auto.Encoder, input)
+	if auto.bias {
+		addBias(hidden, auto.EncoderBias)
+	}
+	hidden.Apply(func(i, j int, v float64) float64 {
+		return sigmoid(v)
+	}, hidden)
+	return hidden
+}
+
+// Add a bias to each column of a matrix.
+func addBias(m *mat.Dense, bias []float64) {
+	m.Apply(func(i, j int, v float64) float64 {
+		return v + bias[i]
+	}, m)
+}
+
+// Update a bias by the sum of gradients of columns.
+func updateBias(bias []float64, grad *mat.Dense, lr float64) {
+	for i := range bias {
+		bias[i] -= lr * mat.Sum(grad.RowView(i))
+	}
+}
+
+// Add the gradient of L2 regularization to a gradient.
+func regularize(grad, weight *mat.Dense, reg float64) {
+	grad.Apply(func(i, j int, v float64) float64 {
+		return v + reg*weight.At(i, j)
+	}, grad)
+}
+
+// Element-wise square of a matrix.
+func squareElem(m *mat.Dense) *mat.Dense {
+	r, c := m.Dims()
+	ret := mat.NewDense(r, c, nil)
+	ret.MulElem(m, m)
+	return ret
+}
@@ -0,0 +1,229 @@
+package core
+
+import (
+	"golang.org/x/exp/rand"
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distmat"
+	"gonum.org/v1/gonum/stat/distmv"
+	"math"
+)
+
+// BPMF: Bayesian Probabilistic Matrix Factorization[21]. Ratings are generated
+// from latent factors with Gaussian noise:
+//
+//   r_{ui} \sim N(μ + p_u^Tq_i, α^{-1})
+//
+// where μ is the global mean. Instead of a fixed regularization, priors of
+// factors are Gaussian whose hyperparameters are given Gaussian-Wishart priors:
+//
+//   p_u \sim N(μ_U, Λ_U^{-1}), μ_U \sim N(μ_0, (β_0Λ_U)^{-1}), Λ_U \sim W(W_0, ν_0)
+//
+// The same applies to q_i. Factors and hyperparameters are sampled from their
+// posterior by Gibbs sampling. The prediction is the average over samples after
+// burn-in and the variance of sampled predictions measures the uncertainty. Only
+// every thin-th sample is kept to bound the memory.
+//
+// [21] Salakhutdinov, Ruslan, and Andriy Mnih. "Bayesian probabilistic matrix
+// factorization using Markov chain Monte Carlo." Proceedings of the 25th
+// international conference on Machine learning. ACM, 2008.
+type BPMF struct {
+	Base
+	UserFactors [][][]float64 // Samples of p_u
+	ItemFactors [][][]float64 // Samples of q_i
+	GlobalMean  float64       // μ
+	Variance    float64       // The variance of ratings in the train set
+	// Hyper parameters
+	nFactors   int
+	nEpochs    int
+	burnIn     int
+	thin       int
+	alpha      float64
+	beta0      float64
+	initMean   float64
+	initStdDev float64
+	// Random source of distributions
+	src rand.Source
+}
+
+// NewBPMF creates a BPMF model. Parameters:
+//	 nFactors	- The number of latent factors. Default is 10.
+//	 nEpochs	- The number of iteration of the Gibbs sampling. Default is 50.
+//	 burnIn		- The number of samples discarded at the beginning. Default is 10.
+//	 thin		- The interval between kept samples after burn-in, which must be at least 1. Default is 2.
+//	 alpha		- The precision α of observed ratings. Default is 2.
+//	 beta0		- The scale β_0 of the precision of hyperparameters μ_U and μ_I. Default is 2.
+//	 initMean	- The mean of initial random latent factors. Default is 0.
+//	 initStdDev	- The standard deviation of initial random latent factors. Default is 0.1.
+func NewBPMF(params Parameters) *BPMF {
+	bpmf := new(BPMF)
+	bpmf.SetParams(params)
+	return bpmf
+}
+
+// SetParams sets hyper parameters.
+func (bpmf *BPMF) SetParams(params Parameters) {
+	bpmf.Base.SetParams(params)
+	bpmf.nFactors = bpmf.Params.GetInt("nFactors", 10)
+	bpmf.nEpochs = bpmf.Params.GetInt("nEpochs", 50)
+	bpmf.burnIn = bpmf.Params.GetInt("burnIn", 10)
+	bpmf.thin = bpmf.Params.GetInt("thin", 2)
+	if bpmf.thin < 1 {
+		panic("BPMF: thin must be at least 1")
+	}
+	bpmf.alpha = bpmf.Params.GetFloat64("alpha", 2)
+	bpmf.beta0 = bpmf.Params.GetFloat64("beta0", 2)
+	bpmf.initMean = bpmf.Params.GetFloat64("initMean", 0)
+	bpmf.initStdDev = bpmf.Params.GetFloat64("initStdDev", 0.1)
+}
+
+// Predict by a BPMF model.
+func (bpmf *BPMF) Predict(userId, itemId int) float64 {
+	mean, _ := bpmf.PredictWithVariance(userId, itemId)
+	return mean
+}
+
+// PredictWithVariance returns the mean and the variance of predictions over
+// samples. If the user or the item is new, the global mean and the variance of
+// ratings in the train set are returned.
+func (bpmf *BPMF) PredictWithVariance(userId, itemId int) (float64, float64) {
+	innerUserId := bpmf.Data.ConvertUserId(userId)
+	innerItemId := bpmf.Data.ConvertItemId(itemId)
+	if innerUserId == NewId || innerItemId == NewId || len(bpmf.UserFactors) == 0 {
+		return bpmf.GlobalMean, bpmf.Variance
+	}
+	sum, sqrSum := 0.0, 0.0
+	for s := range bpmf.UserFactors {
+		pred := floats.Dot(bpmf.UserFactors[s][innerUserId], bpmf.ItemFactors[s][innerItemId])
+		sum += pred
+		sqrSum += pred * pred
+	}
+	n := float64(len(bpmf.UserFactors))
+	mean := sum / n
+	return bpmf.GlobalMean + mean, math.Max(sqrSum/n-mean*mean, 0)
+}
+
+// Fit a BPMF model.
+func (bpmf *BPMF) Fit(trainSet TrainSet) {
+	bpmf.Base.Fit(trainSet)
+	bpmf.src = rand.NewSource(uint64(bpmf.randState))
+	bpmf.GlobalMean = trainSet.GlobalMean
+	bpmf.Variance = trainSet.StdDev() * trainSet.StdDev()
+	nSamples := maxInt(0, (bpmf.nEpochs-bpmf.burnIn+bpmf.thin-1)/bpmf.thin)
+	bpmf.UserFactors = make([][][]float64, 0, nSamples)
+	bpmf.ItemFactors = make([][][]float64, 0, nSamples)
+	// Initialize parameters
+	userFactor := bpmf.newNormalMatrix(trainSet.UserCount, bpmf.nFactors, bpmf.initMean, bpmf.initStdDev)
+	itemFactor := bpmf.newNormalMatrix(trainSet.ItemCount, bpmf.nFactors, bpmf.initMean, bpmf.initStdDev)
+	userRatings := trainSet.UserRatings()
+	itemRatings := trainSet.ItemRatings()
+	// Gibbs sampling
+	for epoch := 0; epoch < bpmf.nEpochs; epoch++ {
+		// Sample hyperparameters and factors of items
+		itemMean, itemPrec := bpmf.sampleHyper(itemFactor)
+		itemFactor = bpmf.sampleFactors(itemRatings, userFactor, itemMean, itemPrec)
+		// Sample hyperparameters and factors of users
+		userMean, userPrec := bpmf.sampleHyper(userFactor)
+		userFactor = bpmf.sampleFactors(userRatings, itemFactor, userMean, userPrec)
+		// Collect every thin-th sample after burn-in
+		if epoch >= bpmf.burnIn && (epoch-bpmf.burnIn)%bpmf.thin == 0 {
+			bpmf.UserFactors = append(bpmf.UserFactors, userFactor)
+			bpmf.ItemFactors = append(bpmf.ItemFactors, itemFactor)
+		}
+	}
+}
+
+// Sample hyperparameters μ and Λ of factors from the Gaussian-Wishart posterior:
+//
+//   Λ \sim W(W^*, ν_0 + N), μ \sim N(μ^*, ((β_0 + N)Λ)^{-1})
+//
+// where μ^* = (β_0μ_0 + N\bar{x})/(β_0 + N), \bar{x} and S are the mean and the
+// covariance of N factors and
+//
+//   (W^*)^{-1} = W_0^{-1} + NS + \frac{β_0N}{β_0 + N}(μ_0 - \bar{x})(μ_0 - \bar{x})^T
+//
+// Here, μ_0 = 0, W_0 = I and ν_0 is the number of factors.
+func (bpmf *BPMF) sampleHyper(factors [][]float64) ([]float64, *mat.SymDense) {
+	n := float64(len(factors))
+	// Compute the mean
+	mean := make([]float64, bpmf.nFactors)
+	for _, factor := range factors {
+		floats.Add(mean, factor)
+	}
+	floats.Scale(1/n, mean)
+	// Compute (W^*)^{-1}
+	wInv := mat.NewSymDense(bpmf.nFactors, nil)
+	for f := 0; f < bpmf.nFactors; f++ {
+		wInv.SetSym(f, f, 1)
+	}
+	diff := mat.NewVecDense(bpmf.nFactors, nil)
+	for _, factor := range factors {
+		floats.SubTo(diff.RawVector().Data, factor, mean)
+		wInv.SymRankOne(wInv, 1, diff)
+	}
+	wInv.SymRankOne(wInv, bpmf.beta0*n/(bpmf.beta0+n), mat.NewVecDense(bpmf.nFactors, mean))
+	// Sample Λ
+	var chol mat.Cholesky
+	if !chol.Factorize(wInv) {
+		panic("BPMF: (W^*)^{-1} is not positive definite")
+	}
+	w := mat.NewSymDense(bpmf.nFactors, nil)
+	if err := chol.InverseTo(w); err != nil {
+		panic(err)
+	}
+	wishart, ok := distmat.NewWishart(w, float64(bpmf.nFactors)+n, bpmf.src)
+	if !ok {
+		panic("BPMF: W^* is not positive definite")
+	}
+	prec := wishart.RandSym(nil)
+	// Sample μ
+	floats.Scale(n/(bpmf.beta0+n), mean)
+	scaledPrec := mat.NewSymDense(bpmf.nFactors, nil)
+	scaledPrec.ScaleSym(bpmf.beta0+n, prec)
+	normal, ok := distmv.NewNormalPrecision(mean, scaledPrec, bpmf.src)
+	if !ok {
+		panic("BPMF: (β_0 + N)Λ is not positive definite")
+	}
+	return normal.Rand(nil), prec
+}
+
+// Sample factors of users (items) given factors of items (users) and
+// hyperparameters. The posterior of the factor x of a user (item) is
+// N(μ^*, (Λ^*)^{-1}) where
+//
+//   Λ^* = Λ + α\sum_{j \in I}y_jy_j^T, μ^* = (Λ^*)^{-1}(α\sum_{j \in I}(r_j - μ)y_j + Λμ)
+//
+// and y_j are factors of rated items (users).
+func (bpmf *BPMF) sampleFactors(ratings [][]IdRating, otherFactors [][]float64,
+	mean []float64, prec *mat.SymDense) [][]float64 {
+	factors := make([][]float64, len(ratings))
+	// Λμ
+	precMean := mat.NewVecDense(bpmf.nFactors, nil)
+	precMean.MulVec(prec, mat.NewVecDense(bpmf.nFactors, mean))
+	for i, irs := range ratings {
+		postPrec := mat.NewSymDense(bpmf.nFactors, nil)
+		postPrec.CopySym(prec)
+		b := mat.NewVecDense(bpmf.nFactors, nil)
+		b.CopyVec(precMean)
+		for _, ir := range irs {
+			y := mat.NewVecDense(bpmf.nFactors, otherFactors[ir.Id])
+			postPrec.SymRankOne(postPrec, bpmf.alpha, y)
+			b.AddScaledVec(b, bpmf.alpha*(ir.Rating-bpmf.GlobalMean), y)
+		}
+		// Solve μ^*
+		var chol mat.Cholesky
+		if !chol.Factorize(postPrec) {
+			panic("BPMF: Λ^* is not positive definite")
+		}
+		postMean := mat.NewVecDense(bpmf.nFactors, nil)
+		if err := chol.SolveVec(postMean, b); err != nil {
+			panic(err)
+		}
+		normal, ok := distmv.NewNormalPrecision(postMean.RawVector().Data, postPrec, bpmf.src)
+		if !ok {
+			panic("BPMF: Λ^* is not positive definite")
+		}
+		factors[i] = normal.Rand(nil)
+	}
+	return factors
+}
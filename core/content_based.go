@@ -0,0 +1,124 @@
+package core
+
+import (
+	"gonum.org/v1/gonum/floats"
+	"math"
+)
+
+// Item profile weighting
+const (
+	tfIdf  = "tfidf"
+	binary = "binary"
+)
+
+// ContentBased recommends items similar to items rated by a user in the space of
+// item features. The profile of item i is the normalized vector of weighted features:
+//
+//   tfidf:  w_{i,f} = x_{i,f} \log \frac{N}{n_f}
+//   binary: w_{i,f} = 1 if x_{i,f} \neq 0
+//
+// where N is the number of items with features and n_f is the number of items
+// with feature f. The profile of user u is the normalized sum of profiles of rated
+// items weighted by ratings:
+//
+//   p_u = \sum_{i \in R_u} r_{u,i} w_i
+//
+// The prediction is the cosine similarity between p_u and w_i. Item features
+// should be set to ItemFeatures before fitting. Items not in the train set are
+// predicted by their features as well.
+type ContentBased struct {
+	Base
+	ItemFeatures map[int]SparseVector // itemId -> features
+	IDF          []float64            // \log \frac{N}{n_f}
+	ItemProfiles map[int]SparseVector // itemId -> w_i
+	UserProfiles [][]float64          // p_u
+	// Hyper parameters
+	weighting string
+}
+
+// NewContentBased creates a content-based model. Parameters:
+//   weighting  - The weighting of item profiles: "tfidf" or "binary". Default is "tfidf".
+func NewContentBased(params Parameters) *ContentBased {
+	cb := new(ContentBased)
+	cb.SetParams(params)
+	return cb
+}
+
+// SetParams sets hyper parameters.
+func (cb *ContentBased) SetParams(params Parameters) {
+	cb.Base.SetParams(params)
+	cb.weighting = cb.Params.GetString("weighting", tfIdf)
+}
+
+// Predict by a content-based model.
+func (cb *ContentBased) Predict(userId, itemId int) float64 {
+	innerUserId := cb.Data.ConvertUserId(userId)
+	if innerUserId == NewId {
+		return 0
+	}
+	profile, exist := cb.ItemProfiles[itemId]
+	if !exist {
+		return 0
+	}
+	ret := 0.0
+	for i, index := range profile.Indices {
+		ret += cb.UserProfiles[innerUserId][index] * profile.Values[i]
+	}
+	return ret
+}
+
+// Fit a content-based model.
+func (cb *ContentBased) Fit(trainSet TrainSet) {
+	cb.Base.Fit(trainSet)
+	nFeatures := featureDim(cb.ItemFeatures)
+	// Compute inverse document frequencies
+	cb.IDF = make([]float64, nFeatures)
+	for _, features := range cb.ItemFeatures {
+		for i, index := range features.Indices {
+			if features.Values[i] != 0 {
+				cb.IDF[index]++
+			}
+		}
+	}
+	for f, df := range cb.IDF {
+		if df > 0 {
+			cb.IDF[f] = math.Log(float64(len(cb.ItemFeatures)) / df)
+		}
+	}
+	// Build item profiles
+	cb.ItemProfiles = make(map[int]SparseVector)
+	for itemId, features := range cb.ItemFeatures {
+		profile := SparseVector{}
+		norm := 0.0
+		for i, index := range features.Indices {
+			if features.Values[i] == 0 {
+				continue
+			}
+			weight := 1.0
+			if cb.weighting == tfIdf {
+				weight = features.Values[i] * cb.IDF[index]
+			}
+			if weight != 0 {
+				profile.Add(index, weight)
+				norm += weight * weight
+			}
+		}
+		if norm > 0 {
+			divConst(math.Sqrt(norm), profile.Values)
+		}
+		cb.ItemProfiles[itemId] = profile
+	}
+	// Build user profiles
+	cb.UserProfiles = newZeroMatrix(trainSet.UserCount, nFeatures)
+	for u, irs := range trainSet.UserRatings() {
+		for _, ir := range irs {
+			profile := cb.ItemProfiles[trainSet.OuterItemId(ir.Id)]
+			for i, index := range profile.Indices {
+				cb.UserProfiles[u][index] += ir.Rating * profile.Values[i]
+			}
+		}
+		if norm := floats.Norm(cb.UserProfiles[u], 2); norm > 0 {
+			divConst(norm, cb.UserProfiles[u])
+		}
+	}
+}
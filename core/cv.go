@@ -3,7 +3,6 @@ package core
 import (
 	"gonum.org/v1/gonum/stat"
 	"math"
-	"reflect"
 )
 
 // ParameterGrid contains candidate for grid search.
@@ -29,8 +28,7 @@ func CrossValidate(estimator Model, dataSet DataSet, metrics []Evaluator, splitt
 	}
 	// Cross validation
 	parallel(length, nJobs, func(begin, end int) {
-		cp := reflect.New(reflect.TypeOf(estimator).Elem()).Interface().(Model)
-		Copy(cp, estimator)
+		cp := copyModel(estimator)
 		for i := begin; i < end; i++ {
 			trainFold := trainFolds[i]
 			testFold := testFolds[i]
@@ -17,6 +17,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 /* Built-in */
@@ -27,6 +28,10 @@ type _BuiltInDataSet struct {
 	path   string
 	sep    string
 	loader func(string, string, bool) DataSet
+	// Item features
+	itemPath  string
+	itemSep   string
+	itemBegin int
 }
 
 var builtInDataSets = map[string]_BuiltInDataSet{
@@ -35,6 +40,10 @@ var builtInDataSets = map[string]_BuiltInDataSet{
 		path:   "ml-100k/u.data",
 		sep:    "\t",
 		loader: LoadDataFromFile,
+		// Genres
+		itemPath:  "ml-100k/u.item",
+		itemSep:   "|",
+		itemBegin: 5,
 	},
 	"ml-1m": {
 		url:    "https://cdn.sine-x.com/datasets/movielens/ml-1m.zip",
@@ -82,6 +91,7 @@ func init() {
 type DataSet interface {
 	Length() int
 	Index(i int) (int, int, float64)
+	Timestamp(i int) int64
 	Mean() float64
 	StdDev() float64
 	Min() float64
@@ -90,11 +100,12 @@ type DataSet interface {
 	SubSet(indices []int) DataSet
 }
 
-// RawDataSet is an array of (userId, itemId, rating).
+// RawDataSet is an array of (userId, itemId, rating) with optional timestamps.
 type RawDataSet struct {
-	Ratings []float64
-	Users   []int
-	Items   []int
+	Ratings    []float64
+	Users      []int
+	Items      []int
+	Timestamps []int64
 }
 
 // NewRawDataSet creates a new raw data set.
@@ -106,6 +117,16 @@ func NewRawDataSet(users, items []int, ratings []float64) *RawDataSet {
 	}
 }
 
+// NewRawDataSetWithTimestamp creates a new raw data set with timestamps.
+func NewRawDataSetWithTimestamp(users, items []int, ratings []float64, timestamps []int64) *RawDataSet {
+	return &RawDataSet{
+		Users:      users,
+		Items:      items,
+		Ratings:    ratings,
+		Timestamps: timestamps,
+	}
+}
+
 // Length returns the number of ratings in the data set.
 func (dataSet *RawDataSet) Length() int {
 	return len(dataSet.Ratings)
@@ -116,6 +137,15 @@ func (dataSet *RawDataSet) Index(i int) (int, int, float64) {
 	return dataSet.Users[i], dataSet.Items[i], dataSet.Ratings[i]
 }
 
+// Timestamp returns the timestamp (seconds since epoch) of the i-th rating.
+// Zero is returned if timestamps are not loaded.
+func (dataSet *RawDataSet) Timestamp(i int) int64 {
+	if dataSet.Timestamps == nil {
+		return 0
+	}
+	return dataSet.Timestamps[i]
+}
+
 func (dataSet *RawDataSet) ForEach(f func(userId, itemId int, rating float64)) {
 	for i := 0; i < dataSet.Length(); i++ {
 		f(dataSet.Users[i], dataSet.Items[i], dataSet.Ratings[i])
@@ -166,6 +196,11 @@ func (dataSet *VirtualDataSet) Index(i int) (int, int, float64) {
 	return dataSet.data.Index(indexInData)
 }
 
+func (dataSet *VirtualDataSet) Timestamp(i int) int64 {
+	indexInData := dataSet.index[i]
+	return dataSet.data.Timestamp(indexInData)
+}
+
 func (dataSet *VirtualDataSet) ForEach(f func(userId, itemId int, rating float64)) {
 	for i := 0; i < dataSet.Length(); i++ {
 		userId, itemId, rating := dataSet.Index(i)
@@ -236,11 +271,20 @@ func (dataSet *RawDataSet) ToCSV(fileName string, sep string) error {
 	if err == nil {
 		writer := bufio.NewWriter(file)
 		for i := range dataSet.Ratings {
-			writer.WriteString(fmt.Sprintf("%v%s%v%s%v\n",
-				dataSet.Users[i], sep,
-				dataSet.Items[i], sep,
-				dataSet.Ratings[i]))
+			if dataSet.Timestamps != nil {
+				writer.WriteString(fmt.Sprintf("%v%s%v%s%v%s%v\n",
+					dataSet.Users[i], sep,
+					dataSet.Items[i], sep,
+					dataSet.Ratings[i], sep,
+					dataSet.Timestamps[i]))
+			} else {
+				writer.WriteString(fmt.Sprintf("%v%s%v%s%v\n",
+					dataSet.Users[i], sep,
+					dataSet.Items[i], sep,
+					dataSet.Ratings[i]))
+			}
 		}
+		writer.Flush()
 	}
 	return err
 }
@@ -267,6 +311,7 @@ type TrainSet struct {
 	outerItemIds []int
 	userRatings  [][]IdRating
 	itemRatings  [][]IdRating
+	owned        bool // Whether ratings and ID maps are owned by the train set
 }
 
 // <userId, rating> or <itemId, rating>
@@ -275,6 +320,31 @@ type IdRating struct {
 	Rating float64
 }
 
+// SparseVector is a vector of <index, value>s. Missing indices are zeros.
+type SparseVector struct {
+	Indices []int
+	Values  []float64
+}
+
+// NewSparseVector creates a sparse vector.
+func NewSparseVector(indices []int, values []float64) SparseVector {
+	return SparseVector{
+		Indices: indices,
+		Values:  values,
+	}
+}
+
+// Add a <index, value> to the sparse vector.
+func (vec *SparseVector) Add(index int, value float64) {
+	vec.Indices = append(vec.Indices, index)
+	vec.Values = append(vec.Values, value)
+}
+
+// Len returns the number of non-zero elements.
+func (vec *SparseVector) Len() int {
+	return len(vec.Indices)
+}
+
 // An ID not existed in the data set.
 const NewId = -1
 
@@ -306,6 +376,157 @@ func NewTrainSet(rawSet DataSet) TrainSet {
 	return set
 }
 
+// AddUser registers a new user without ratings and returns the inner user ID. The
+// inner user ID is returned if the user exists. The train set takes its own copy of
+// ratings and ID maps before being modified, as Append does.
+func (trainSet *TrainSet) AddUser(userId int) int {
+	if innerUserId, exist := trainSet.InnerUserIds[userId]; exist {
+		return innerUserId
+	}
+	trainSet.own()
+	trainSet.addUser(userId)
+	return trainSet.UserCount - 1
+}
+
+// Append appends ratings to the train set and returns a train set of appended
+// ratings only, which shares ID maps with the train set. New users and items are
+// registered in ID maps. At the first modification, the train set takes its own
+// copy of ratings and ID maps so that other train sets sharing them aren't affected.
+// Later modifications are made in place in O(n) time for n appended ratings, so
+// copies of the train set made after the first modification share them.
+func (trainSet *TrainSet) Append(dataSet DataSet) TrainSet {
+	trainSet.own()
+	rawSet := trainSet.DataSet.(*RawDataSet)
+	length := rawSet.Length()
+	sum := trainSet.GlobalMean * float64(length)
+	for i := 0; i < dataSet.Length(); i++ {
+		userId, itemId, rating := dataSet.Index(i)
+		timestamp := dataSet.Timestamp(i)
+		// Register new users and items
+		if _, exist := trainSet.InnerUserIds[userId]; !exist {
+			trainSet.addUser(userId)
+		}
+		if _, exist := trainSet.InnerItemIds[itemId]; !exist {
+			trainSet.addItem(itemId)
+		}
+		// Append the rating
+		if timestamp != 0 && rawSet.Timestamps == nil {
+			rawSet.Timestamps = make([]int64, rawSet.Length(), cap(rawSet.Ratings))
+		}
+		rawSet.Users = append(rawSet.Users, userId)
+		rawSet.Items = append(rawSet.Items, itemId)
+		rawSet.Ratings = append(rawSet.Ratings, rating)
+		if rawSet.Timestamps != nil {
+			rawSet.Timestamps = append(rawSet.Timestamps, timestamp)
+		}
+		sum += rating
+		// Update ratings of users and items
+		innerUserId := trainSet.InnerUserIds[userId]
+		innerItemId := trainSet.InnerItemIds[itemId]
+		if trainSet.userRatings != nil {
+			trainSet.userRatings[innerUserId] = append(trainSet.userRatings[innerUserId], IdRating{innerItemId, rating})
+		}
+		if trainSet.itemRatings != nil {
+			trainSet.itemRatings[innerItemId] = append(trainSet.itemRatings[innerItemId], IdRating{innerUserId, rating})
+		}
+	}
+	if rawSet.Length() > 0 {
+		trainSet.GlobalMean = sum / float64(rawSet.Length())
+	}
+	// Create the train set of appended ratings
+	delta := *trainSet
+	delta.DataSet = dataSet
+	delta.GlobalMean = dataSet.Mean()
+	delta.userRatings, delta.itemRatings = nil, nil
+	delta.owned = false
+	return delta
+}
+
+// Take own copies of ratings and ID maps if the train set doesn't own them. Rows of
+// ratings of users and items are clipped so that appending to them reallocates.
+func (trainSet *TrainSet) own() {
+	if trainSet.owned {
+		return
+	}
+	// Copy ratings
+	length := trainSet.Length()
+	rawSet := &RawDataSet{
+		Users:   make([]int, length),
+		Items:   make([]int, length),
+		Ratings: make([]float64, length),
+	}
+	for i := 0; i < length; i++ {
+		rawSet.Users[i], rawSet.Items[i], rawSet.Ratings[i] = trainSet.Index(i)
+		if timestamp := trainSet.Timestamp(i); timestamp != 0 {
+			if rawSet.Timestamps == nil {
+				rawSet.Timestamps = make([]int64, length)
+			}
+			rawSet.Timestamps[i] = timestamp
+		}
+	}
+	trainSet.DataSet = rawSet
+	// Copy ID maps
+	innerUserIds := make(map[int]int, len(trainSet.InnerUserIds))
+	for id, innerId := range trainSet.InnerUserIds {
+		innerUserIds[id] = innerId
+	}
+	innerItemIds := make(map[int]int, len(trainSet.InnerItemIds))
+	for id, innerId := range trainSet.InnerItemIds {
+		innerItemIds[id] = innerId
+	}
+	trainSet.InnerUserIds, trainSet.InnerItemIds = innerUserIds, innerItemIds
+	trainSet.outerUserIds = append([]int{}, trainSet.outerUserIds...)
+	trainSet.outerItemIds = append([]int{}, trainSet.outerItemIds...)
+	// Clip ratings of users and items
+	trainSet.userRatings = clipRows(trainSet.userRatings)
+	trainSet.itemRatings = clipRows(trainSet.itemRatings)
+	trainSet.owned = true
+}
+
+// Register a new user in the train set.
+func (trainSet *TrainSet) addUser(userId int) {
+	trainSet.InnerUserIds[userId] = trainSet.UserCount
+	trainSet.outerUserIds = append(trainSet.outerUserIds, userId)
+	if trainSet.userRatings != nil {
+		trainSet.userRatings = append(trainSet.userRatings, []IdRating{})
+	}
+	trainSet.UserCount++
+}
+
+// Register a new item in the train set.
+func (trainSet *TrainSet) addItem(itemId int) {
+	trainSet.InnerItemIds[itemId] = trainSet.ItemCount
+	trainSet.outerItemIds = append(trainSet.outerItemIds, itemId)
+	if trainSet.itemRatings != nil {
+		trainSet.itemRatings = append(trainSet.itemRatings, []IdRating{})
+	}
+	trainSet.ItemCount++
+}
+
+// Copy the outer slice of rows and clip the capacity of each row to its length.
+func clipRows(rows [][]IdRating) [][]IdRating {
+	if rows == nil {
+		return nil
+	}
+	ret := make([][]IdRating, len(rows))
+	for i, row := range rows {
+		ret[i] = row[:len(row):len(row)]
+	}
+	return ret
+}
+
+// Return a train set iterating over given ratings, which are appended to the train set
+// already. Ratings of users and items come from all ratings in the train set, so that
+// negatives are sampled against all positives in the train set.
+func (trainSet *TrainSet) withSamples(dataSet DataSet) TrainSet {
+	trainSet.UserRatings()
+	trainSet.ItemRatings()
+	samples := *trainSet
+	samples.DataSet = dataSet
+	samples.owned = false
+	return samples
+}
+
 // RatingRange gets the range of ratings. Return minimum and maximum.
 func (trainSet *TrainSet) RatingRange() (float64, float64) {
 	return trainSet.Min(), trainSet.Max()
@@ -327,6 +548,16 @@ func (trainSet *TrainSet) ConvertItemId(itemId int) int {
 	return NewId
 }
 
+// OuterUserId converts inner user ID to user ID.
+func (trainSet *TrainSet) OuterUserId(innerUserId int) int {
+	return trainSet.outerUserIds[innerUserId]
+}
+
+// OuterItemId converts inner item ID to item ID.
+func (trainSet *TrainSet) OuterItemId(innerItemId int) int {
+	return trainSet.outerItemIds[innerItemId]
+}
+
 // UserRatings: an array of <itemId, rating> for each user.
 func (trainSet *TrainSet) UserRatings() [][]IdRating {
 	if trainSet.userRatings == nil {
@@ -343,6 +574,17 @@ func (trainSet *TrainSet) UserRatings() [][]IdRating {
 	return trainSet.userRatings
 }
 
+// UserIndices: an array of rating indices for each user.
+func (trainSet *TrainSet) UserIndices() [][]int {
+	userIndices := make([][]int, trainSet.UserCount)
+	for i := 0; i < trainSet.Length(); i++ {
+		userId, _, _ := trainSet.Index(i)
+		innerUserId := trainSet.ConvertUserId(userId)
+		userIndices[innerUserId] = append(userIndices[innerUserId], i)
+	}
+	return userIndices
+}
+
 // ItemRatings: an array of <userId, Rating> for each item.
 func (trainSet *TrainSet) ItemRatings() [][]IdRating {
 	if trainSet.itemRatings == nil {
@@ -380,14 +622,35 @@ func LoadDataFromBuiltIn(dataSetName string) DataSet {
 	return dataSet.loader(dataFileName, dataSet.sep, false)
 }
 
+// LoadItemFeaturesFromBuiltIn loads item features of a built-in data set. Now support:
+//   ml-100k	- Genres of movies in MovieLens 100K
+func LoadItemFeaturesFromBuiltIn(dataSetName string) map[int]SparseVector {
+	// Extract data set information
+	dataSet, exist := builtInDataSets[dataSetName]
+	if !exist {
+		log.Fatal("no such data set ", dataSetName)
+	}
+	if dataSet.itemPath == "" {
+		log.Fatal("no item features in data set ", dataSetName)
+	}
+	itemFileName := filepath.Join(dataSetDir, dataSet.itemPath)
+	if _, err := os.Stat(itemFileName); os.IsNotExist(err) {
+		zipFileName, _ := downloadFromUrl(dataSet.url, downloadDir)
+		unzip(zipFileName, dataSetDir)
+	}
+	return LoadItemFeaturesFromFile(itemFileName, dataSet.itemSep, false, dataSet.itemBegin)
+}
+
 // LoadDataFromFile loads data from a text file. The text file should be:
 //
 //   [optional header]
-// 	 <userId 1> <sep> <itemId 1> <sep> <rating 1> <sep> <extras>
-// 	 <userId 2> <sep> <itemId 2> <sep> <rating 2> <sep> <extras>
-// 	 <userId 3> <sep> <itemId 3> <sep> <rating 3> <sep> <extras>
+// 	 <userId 1> <sep> <itemId 1> <sep> <rating 1> <sep> <timestamp 1> <sep> <extras>
+// 	 <userId 2> <sep> <itemId 2> <sep> <rating 2> <sep> <timestamp 2> <sep> <extras>
+// 	 <userId 3> <sep> <itemId 3> <sep> <rating 3> <sep> <timestamp 3> <sep> <extras>
 //	 ...
 //
+// Timestamps are optional. They are loaded if the fourth column exists in all lines.
+//
 // For example, the `u.data` from MovieLens 100K is:
 //
 //  196\t242\t3\t881250949
@@ -398,6 +661,8 @@ func LoadDataFromFile(fileName string, sep string, hasHeader bool) DataSet {
 	users := make([]int, 0)
 	items := make([]int, 0)
 	ratings := make([]float64, 0)
+	timestamps := make([]int64, 0)
+	hasTimestamp := true
 	// Open file
 	file, err := os.Open(fileName)
 	if err != nil {
@@ -420,14 +685,84 @@ func LoadDataFromFile(fileName string, sep string, hasHeader bool) DataSet {
 		users = append(users, user)
 		items = append(items, item)
 		ratings = append(ratings, float64(rating))
+		// Read timestamp
+		if hasTimestamp && len(fields) > 3 {
+			timestamp, err := strconv.ParseInt(fields[3], 10, 64)
+			hasTimestamp = err == nil
+			timestamps = append(timestamps, timestamp)
+		} else {
+			hasTimestamp = false
+		}
+	}
+	if hasTimestamp {
+		return NewRawDataSetWithTimestamp(users, items, ratings, timestamps)
 	}
 	return NewRawDataSet(users, items, ratings)
 }
 
+// LoadItemFeaturesFromFile loads item features from a text file. The text file should be:
+//
+//   [optional header]
+//   <itemId 1> <sep> <extras> <sep> <feature 1> <sep> <feature 2> <sep> ...
+//   <itemId 2> <sep> <extras> <sep> <feature 1> <sep> <feature 2> <sep> ...
+//   <itemId 3> <sep> <extras> <sep> <feature 1> <sep> <feature 2> <sep> ...
+//   ...
+//
+// Features are numbers in columns starting from the column begin. The index of a
+// feature is its column minus begin and zero features are omitted. Items with
+// an ID that fails to parse are skipped.
+//
+// For example, the `u.item` from MovieLens 100K (begin = 5) is:
+//
+//   1|Toy Story (1995)|01-Jan-1995||http://...|0|0|0|1|1|1|0|0|0|0|0|0|0|0|0|0|0|0|0
+//   2|GoldenEye (1995)|01-Jan-1995||http://...|0|1|1|0|0|0|0|0|0|0|0|0|0|0|0|0|1|0|0
+//
+func LoadItemFeaturesFromFile(fileName string, sep string, hasHeader bool, begin int) map[int]SparseVector {
+	features := make(map[int]SparseVector)
+	// Open file
+	file, err := os.Open(fileName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+	// Read file
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Ignore header
+		if hasHeader {
+			hasHeader = false
+			continue
+		}
+		fields := strings.Split(line, sep)
+		itemId, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		vec := SparseVector{}
+		for j := begin; j < len(fields); j++ {
+			if value, err := strconv.ParseFloat(fields[j], 64); err == nil && value != 0 {
+				vec.Add(j-begin, value)
+			}
+		}
+		features[itemId] = vec
+	}
+	return features
+}
+
+// LoadDataFromNetflix loads data from the Netflix Prize data set. The text file should be:
+//
+//   <itemId 1>:
+//   <userId 1>,<rating 1>,<date 1>
+//   <userId 2>,<rating 2>,<date 2>
+//   ...
+//
+// where dates are in the form of YYYY-MM-DD.
 func LoadDataFromNetflix(fileName string, sep string, hasHeader bool) DataSet {
 	users := make([]int, 0)
 	items := make([]int, 0)
 	ratings := make([]float64, 0)
+	timestamps := make([]int64, 0)
 	// Open file
 	file, err := os.Open(fileName)
 	if err != nil {
@@ -449,12 +784,14 @@ func LoadDataFromNetflix(fileName string, sep string, hasHeader bool) DataSet {
 			fields := strings.Split(line, ",")
 			userId, _ := strconv.Atoi(fields[0])
 			rating, _ := strconv.Atoi(fields[1])
+			date, _ := time.Parse("2006-01-02", fields[2])
 			users = append(users, userId)
 			items = append(items, itemId)
 			ratings = append(ratings, float64(rating))
+			timestamps = append(timestamps, date.Unix())
 		}
 	}
-	return NewRawDataSet(users, items, ratings)
+	return NewRawDataSetWithTimestamp(users, items, ratings, timestamps)
 }
 
 // Download file from URL.
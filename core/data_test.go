@@ -46,3 +46,64 @@ func TestUnzip(t *testing.T) {
 		t.Fatal("Number of file doesn't match")
 	}
 }
+
+func TestLoadItemFeaturesFromBuiltIn(t *testing.T) {
+	features := LoadItemFeaturesFromBuiltIn("ml-100k")
+	if len(features) != 1682 {
+		t.Fatalf("Number of items (%d) doesn't match", len(features))
+	}
+	// 19 genres
+	if dim := featureDim(features); dim != 19 {
+		t.Fatalf("Number of genres (%d) doesn't match", dim)
+	}
+}
+
+func TestTrainSet_AddUser(t *testing.T) {
+	trainSet := NewTrainSet(NewRawDataSet([]int{1, 2}, []int{1, 2}, []float64{1, 1}))
+	shared := trainSet
+	if innerUserId := trainSet.AddUser(3); innerUserId != 2 {
+		t.Fatalf("Inner ID of the new user (%d) != 2", innerUserId)
+	}
+	if innerUserId := trainSet.AddUser(1); innerUserId != 0 {
+		t.Fatalf("Inner ID of the existed user (%d) != 0", innerUserId)
+	}
+	if trainSet.UserCount != 3 || trainSet.OuterUserId(2) != 3 || len(trainSet.UserRatings()) != 3 {
+		t.Fatal("The new user isn't registered")
+	}
+	if shared.ConvertUserId(3) != NewId {
+		t.Fatal("The shared train set is modified")
+	}
+}
+
+func TestTrainSet_Append(t *testing.T) {
+	trainSet := NewTrainSet(NewRawDataSet([]int{1, 2}, []int{1, 2}, []float64{1, 1}))
+	shared := trainSet
+	delta := trainSet.Append(NewRawDataSet([]int{1, 3}, []int{3, 2}, []float64{4, 4}))
+	if trainSet.Length() != 4 || trainSet.GlobalMean != 2.5 {
+		t.Fatal("Ratings aren't appended")
+	}
+	if trainSet.UserCount != 3 || trainSet.ItemCount != 3 ||
+		trainSet.ConvertUserId(3) != 2 || trainSet.ConvertItemId(3) != 2 {
+		t.Fatal("New users or items aren't registered")
+	}
+	if len(trainSet.UserRatings()[0]) != 2 || len(trainSet.ItemRatings()[1]) != 2 {
+		t.Fatal("Ratings of users or items aren't updated")
+	}
+	if delta.Length() != 2 || delta.GlobalMean != 4 || delta.UserCount != 3 || delta.ConvertUserId(3) != 2 {
+		t.Fatal("The train set of appended ratings is wrong")
+	}
+	if shared.ConvertUserId(3) != NewId || shared.Length() != 2 {
+		t.Fatal("The shared train set is modified")
+	}
+	// Append in place
+	delta = trainSet.Append(NewRawDataSetWithTimestamp([]int{4}, []int{1}, []float64{5}, []int64{10}))
+	if trainSet.Length() != 5 || trainSet.GlobalMean != 3 || trainSet.Timestamp(4) != 10 || trainSet.Timestamp(0) != 0 {
+		t.Fatal("Ratings aren't appended in place")
+	}
+	if len(trainSet.UserRatings()) != 4 || len(trainSet.UserRatings()[3]) != 1 || len(trainSet.ItemRatings()[0]) != 2 {
+		t.Fatal("Ratings of users or items aren't updated in place")
+	}
+	if delta.Length() != 1 || delta.ConvertUserId(4) != 3 || shared.ConvertUserId(4) != NewId {
+		t.Fatal("The train set of appended ratings is wrong")
+	}
+}
@@ -5,8 +5,38 @@ import (
 	"encoding/gob"
 	"os"
 	"path/filepath"
+	"reflect"
 )
 
+func init() {
+	// Register models and data sets stored in interfaces
+	gob.Register(&RawDataSet{})
+	gob.Register(&VirtualDataSet{})
+	gob.Register(&Random{})
+	gob.Register(&BaseLine{})
+	gob.Register(&ItemPop{})
+	gob.Register(&TrendingPop{})
+	gob.Register(&SVD{})
+	gob.Register(&NMF{})
+	gob.Register(&SVDpp{})
+	gob.Register(&TimeSVDpp{})
+	gob.Register(&WRMF{})
+	gob.Register(&KNN{})
+	gob.Register(&SlopeOne{})
+	gob.Register(&CoClustering{})
+	gob.Register(&FM{})
+	gob.Register(&AutoRec{})
+	gob.Register(&EASE{})
+	gob.Register(&SLIM{})
+	gob.Register(&Ensemble{})
+	gob.Register(&ContentBased{})
+	gob.Register(&LightFM{})
+	gob.Register(&FPMC{})
+	gob.Register(&Item2Vec{})
+	gob.Register(&RP3{})
+	gob.Register(&BPMF{})
+}
+
 // Load a object from file.
 func Load(fileName string, object interface{}) error {
 	file, err := os.Open(fileName)
@@ -33,11 +63,48 @@ func Save(fileName string, object interface{}) error {
 	return err
 }
 
-// Copy a object from src to dst.
-func Copy(dst, src interface{}) {
+// Copy a object from src to dst. An error is returned if src can't be encoded
+// by gob, such as functions in parameters.
+func Copy(dst, src interface{}) error {
 	buffer := new(bytes.Buffer)
 	encoder := gob.NewEncoder(buffer)
-	encoder.Encode(src)
+	if err := encoder.Encode(src); err != nil {
+		return err
+	}
 	decoder := gob.NewDecoder(buffer)
-	decoder.Decode(dst)
+	return decoder.Decode(dst)
+}
+
+// A model containing other models copies itself, since parameters of the inner
+// models can't be encoded.
+type modelCopier interface {
+	copyModel() Model
+}
+
+// Copy a model to a new model of the same type.
+func copyModel(src Model) Model {
+	if copier, ok := src.(modelCopier); ok {
+		return copier.copyModel()
+	}
+	return copyFields(src)
+}
+
+// Copy exported fields of a model by gob. The embedded Base isn't encoded since
+// parameters might contain functions such as optimizers and splitters, and the
+// train set has unexported fields. Hyper parameters are restored from parameters
+// of the source model.
+func copyFields(src Model) Model {
+	// Shallow copy without Base
+	srcValue := reflect.ValueOf(src).Elem()
+	shallow := reflect.New(srcValue.Type())
+	shallow.Elem().Set(srcValue)
+	base := shallow.Elem().FieldByName("Base")
+	base.Set(reflect.Zero(base.Type()))
+	// Deep copy
+	dst := reflect.New(srcValue.Type()).Interface().(Model)
+	if err := Copy(dst, shallow.Interface()); err != nil {
+		panic(err)
+	}
+	dst.SetParams(paramsOf(src))
+	return dst
 }
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"gonum.org/v1/gonum/mat"
+	"runtime"
+)
+
+// Ensemble blends predictions of several models linearly:
+//
+//   \hat{r}_{ui} = w_0 + \sum_k w_k \hat{r}^{(k)}_{ui}
+//
+// Copies of base models are fitted on inner folds generated by a splitter from
+// the train set. Blending weights are learned by ridge regression on out-of-fold
+// predictions and then copies of base models are fitted on the full train set.
+// Base models passed to NewEnsemble are never fitted.
+type Ensemble struct {
+	Base
+	Models    []Model   // Base models
+	Weights   []float64 // w_k
+	Intercept float64   // w_0
+}
+
+// NewEnsemble creates an ensemble of models. Parameters:
+//   splitter   - The splitter to generate inner folds. Default is NewKFoldSplitter(5).
+//	 reg 		- The regularization parameter of ridge regression. Default is 1.
+//	 nJobs		- The number of goroutines to fit models on inner folds. Default is the number of CPUs.
+func NewEnsemble(params Parameters, models ...Model) *Ensemble {
+	ensemble := new(Ensemble)
+	ensemble.SetParams(params)
+	ensemble.Models = models
+	return ensemble
+}
+
+// Predict by an ensemble.
+func (ensemble *Ensemble) Predict(userId, itemId int) float64 {
+	ret := ensemble.Intercept
+	for k, model := range ensemble.Models {
+		ret += ensemble.Weights[k] * model.Predict(userId, itemId)
+	}
+	return ret
+}
+
+// Fit an ensemble.
+func (ensemble *Ensemble) Fit(trainSet TrainSet) {
+	ensemble.Base.Fit(trainSet)
+	// Setup parameters
+	splitter := ensemble.Params.GetSplitter("splitter", NewKFoldSplitter(5))
+	reg := ensemble.Params.GetFloat64("reg", 1)
+	nJobs := ensemble.Params.GetInt("nJobs", runtime.NumCPU())
+	nModels := len(ensemble.Models)
+	// Split train set into inner folds
+	trainFolds, testFolds := splitter(trainSet.DataSet, int64(ensemble.randState))
+	offsets := make([]int, len(testFolds))
+	length := 0
+	for i, testFold := range testFolds {
+		offsets[i] = length
+		length += testFold.Length()
+	}
+	// Collect out-of-fold predictions: X = [\hat{r}^{(1)}, ..., \hat{r}^{(K)}, 1]
+	features := mat.NewDense(length, nModels+1, nil)
+	targets := mat.NewVecDense(length, nil)
+	for i, testFold := range testFolds {
+		for j := 0; j < testFold.Length(); j++ {
+			_, _, rating := testFold.Index(j)
+			features.Set(offsets[i]+j, nModels, 1)
+			targets.SetVec(offsets[i]+j, rating)
+		}
+	}
+	parallel(len(trainFolds), nJobs, func(begin, end int) {
+		for i := begin; i < end; i++ {
+			for k, model := range ensemble.Models {
+				cp := copyModel(model)
+				cp.Fit(trainFolds[i])
+				for j := 0; j < testFolds[i].Length(); j++ {
+					userId, itemId, _ := testFolds[i].Index(j)
+					features.Set(offsets[i]+j, k, cp.Predict(userId, itemId))
+				}
+			}
+		}
+	})
+	// Ridge regression: w = (X^TX + λI)^{-1}X^Tr, where the intercept isn't regularized
+	a := mat.NewSymDense(nModels+1, nil)
+	a.SymOuterK(1, features.T())
+	for k := 0; k < nModels; k++ {
+		a.SetSym(k, k, a.At(k, k)+reg)
+	}
+	b := mat.NewVecDense(nModels+1, nil)
+	b.MulVec(features.T(), targets)
+	ensemble.Weights = make([]float64, nModels)
+	ensemble.Intercept = 0
+	var chol mat.Cholesky
+	w := mat.NewVecDense(nModels+1, nil)
+	if chol.Factorize(a) && chol.SolveVec(w, b) == nil {
+		for k := range ensemble.Weights {
+			ensemble.Weights[k] = w.AtVec(k)
+		}
+		ensemble.Intercept = w.AtVec(nModels)
+	} else {
+		// Fall back to the average of base models if X^TX + λI is singular
+		for k := range ensemble.Weights {
+			ensemble.Weights[k] = 1 / float64(nModels)
+		}
+	}
+	// Fit copies of base models on the full train set
+	models := make([]Model, nModels)
+	for k, model := range ensemble.Models {
+		models[k] = copyModel(model)
+		models[k].Fit(trainSet)
+	}
+	ensemble.Models = models
+}
+
+// Copy an ensemble. Base models are copied one by one.
+func (ensemble *Ensemble) copyModel() Model {
+	shallow := *ensemble
+	shallow.Models = nil
+	cp := copyFields(&shallow).(*Ensemble)
+	cp.Models = make([]Model, len(ensemble.Models))
+	for k, model := range ensemble.Models {
+		cp.Models[k] = copyModel(model)
+	}
+	return cp
+}
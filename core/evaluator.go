@@ -5,28 +5,39 @@ import "math"
 // Evaluator evaluates the performance of a estimator on the test set.
 type Evaluator func(Model, DataSet) float64
 
-// RMSE is root mean square error.
+// RMSE is root mean square error. If the estimator is a TimeModel, ratings are
+// predicted at their timestamps.
 func RMSE(estimator Model, testSet DataSet) float64 {
 	sum := 0.0
 	for j := 0; j < testSet.Length(); j++ {
-		userId, itemId, rating := testSet.Index(j)
-		prediction := estimator.Predict(userId, itemId)
+		_, _, rating := testSet.Index(j)
+		prediction := predictTestRating(estimator, testSet, j)
 		sum += (prediction - rating) * (prediction - rating)
 	}
 	return math.Sqrt(sum / float64(testSet.Length()))
 }
 
-// MAE is mean absolute error.
+// MAE is mean absolute error. If the estimator is a TimeModel, ratings are
+// predicted at their timestamps.
 func MAE(estimator Model, testSet DataSet) float64 {
 	sum := 0.0
 	for j := 0; j < testSet.Length(); j++ {
-		userId, itemId, rating := testSet.Index(j)
-		prediction := estimator.Predict(userId, itemId)
+		_, _, rating := testSet.Index(j)
+		prediction := predictTestRating(estimator, testSet, j)
 		sum += math.Abs(prediction - rating)
 	}
 	return sum / float64(testSet.Length())
 }
 
+// Predict the j-th rating in a test set.
+func predictTestRating(estimator Model, testSet DataSet, j int) float64 {
+	userId, itemId, _ := testSet.Index(j)
+	if timeModel, ok := estimator.(TimeModel); ok {
+		return timeModel.PredictWithTime(userId, itemId, testSet.Timestamp(j))
+	}
+	return estimator.Predict(userId, itemId)
+}
+
 // NewAUCEvaluator creates a AUC evaluator.
 func NewAUCEvaluator(fullSet DataSet) Evaluator {
 	return func(estimator Model, testSet DataSet) float64 {
@@ -1,79 +1,225 @@
 package core
 
-import "gonum.org/v1/gonum/floats"
+import (
+	"math"
+)
 
+// FM task
+const (
+	regression     = "regression"
+	classification = "classification"
+)
+
+// FM: Factorization Machines[9]. The input vector x of a rating is composed of
+// the one-hot encoding of the user, the one-hot encoding of the item, the side
+// features of the user and the side features of the item. The prediction is:
+//
+//   \hat{y}(x) = w_0 + \sum^n_{i=1} w_i x_i + \sum^n_{i=1} \sum^n_{j=i+1} <v_i, v_j> x_i x_j
+//
+// Side features are optional and should be set to UserFeatures and ItemFeatures
+// before fitting. A new user (item) with side features is encoded by its side
+// features only.
 type FM struct {
 	Base
-	UserFactor [][]float64
-	ItemFactor [][]float64
+	// Side features
+	UserFeatures map[int]SparseVector // userId -> features
+	ItemFeatures map[int]SparseVector // itemId -> features
+	// Model parameters
+	GlobalBias float64     // w_0
+	Bias       []float64   // w_i
+	Factor     [][]float64 // v_i
+	// Hyper parameters
+	task       string
+	threshold  float64
+	nFactors   int
+	nEpochs    int
+	lr         float64
+	reg        float64
+	initMean   float64
+	initStdDev float64
+	// Feature space
+	nUserFeatures int
+	nItemFeatures int
 }
 
+// NewFM creates a factorization machine. Parameters:
+//   task       - The type of task: "regression" or "classification". Default is "regression".
+//   threshold  - Ratings greater than the threshold are positive in classification. Default is 0.
+//	 reg 		- The regularization parameter of the cost function that is
+// 				  optimized. Default is 0.02.
+//	 lr 		- The learning rate of SGD. Default is 0.005.
+//	 nFactors	- The number of latent factors. Default is 100.
+//	 nEpochs	- The number of iteration of the SGD procedure. Default is 20.
+//	 initMean	- The mean of initial random latent factors. Default is 0.
+//	 initStdDev	- The standard deviation of initial random latent factors. Default is 0.1.
+//   updater    - The per-parameter updater: "sgd", "adagrad", "rmsprop" or "adam". Default is "sgd".
+//   lrSchedule - The learning rate schedule: "constant", "step", "exponential" or "inverse".
+//                Default is "constant".
+//   lrDecay    - The decay rate of the learning rate schedule. Default is 0.9.
+//   lrStepSize - The number of epochs between decays of the step schedule. Default is 10.
 func NewFM(params Parameters) *FM {
 	fm := new(FM)
 	fm.SetParams(params)
 	return fm
 }
 
+// SetParams sets hyper parameters.
+func (fm *FM) SetParams(params Parameters) {
+	fm.Base.SetParams(params)
+	fm.task = fm.Params.GetString("task", regression)
+	fm.threshold = fm.Params.GetFloat64("threshold", 0)
+	fm.nFactors = fm.Params.GetInt("nFactors", 100)
+	fm.nEpochs = fm.Params.GetInt("nEpochs", 20)
+	fm.lr = fm.Params.GetFloat64("lr", 0.005)
+	fm.reg = fm.Params.GetFloat64("reg", 0.02)
+	fm.initMean = fm.Params.GetFloat64("initMean", 0)
+	fm.initStdDev = fm.Params.GetFloat64("initStdDev", 0.1)
+}
+
+// Predict by a factorization machine. The probability of positive
+// is returned in classification.
 func (fm *FM) Predict(userId, itemId int) float64 {
-	innerUserId := fm.Data.ConvertUserId(userId)
-	innerItemId := fm.Data.ConvertItemId(itemId)
-	if innerUserId == NewId || innerItemId == NewId {
-		return 0
+	ret := fm.predict(fm.encode(userId, itemId))
+	if fm.task == classification {
+		return sigmoid(ret)
+	}
+	return ret
+}
+
+func (fm *FM) predict(x SparseVector) float64 {
+	ret := fm.GlobalBias
+	// \sum^n_{i=1} w_i x_i
+	for i, index := range x.Indices {
+		ret += fm.Bias[index] * x.Values[i]
+	}
+	// \frac{1}{2} \sum^k_{f=1} \left( \left( \sum^n_{i=1} v_{i,f} x_i \right)^2 - \sum^n_{i=1} v^2_{i,f} x^2_i \right)
+	for f := 0; f < fm.nFactors; f++ {
+		sum, sumSquare := 0.0, 0.0
+		for i, index := range x.Indices {
+			vx := fm.Factor[index][f] * x.Values[i]
+			sum += vx
+			sumSquare += vx * vx
+		}
+		ret += 0.5 * (sum*sum - sumSquare)
+	}
+	return ret
+}
+
+// Encode a <userId, itemId> pair to a feature vector. The feature space is:
+//
+//   [users | items | user features | item features]
+//
+func (fm *FM) encode(userId, itemId int) SparseVector {
+	x := SparseVector{}
+	if innerUserId := fm.Data.ConvertUserId(userId); innerUserId != NewId {
+		x.Add(innerUserId, 1)
+	}
+	if innerItemId := fm.Data.ConvertItemId(itemId); innerItemId != NewId {
+		x.Add(fm.Data.UserCount+innerItemId, 1)
 	}
-	return floats.Dot(fm.UserFactor[innerUserId], fm.ItemFactor[innerItemId])
+	offset := fm.Data.UserCount + fm.Data.ItemCount
+	if features, exist := fm.UserFeatures[userId]; exist {
+		for i, index := range features.Indices {
+			if index < fm.nUserFeatures {
+				x.Add(offset+index, features.Values[i])
+			}
+		}
+	}
+	offset += fm.nUserFeatures
+	if features, exist := fm.ItemFeatures[itemId]; exist {
+		for i, index := range features.Indices {
+			if index < fm.nItemFeatures {
+				x.Add(offset+index, features.Values[i])
+			}
+		}
+	}
+	return x
 }
 
-func (fm *FM) Fit(set TrainSet) {
-	fm.Base.Fit(set)
-	// Setup parameters
-	nFactors := fm.Params.GetInt("nFactors", 100)
-	nEpochs := fm.Params.GetInt("nEpochs", 20)
-	lr := fm.Params.GetFloat64("lr", 0.005)
-	reg := fm.Params.GetFloat64("reg", 0.02)
-	initMean := fm.Params.GetFloat64("initMean", 0)
-	initStdDev := fm.Params.GetFloat64("initStdDev", 0.1)
+// Fit a factorization machine.
+func (fm *FM) Fit(trainSet TrainSet) {
+	fm.Base.Fit(trainSet)
 	// Initialize parameters
-	fm.UserFactor = fm.newNormalMatrix(set.UserCount, nFactors, initMean, initStdDev)
-	fm.ItemFactor = fm.newNormalMatrix(set.ItemCount, nFactors, initMean, initStdDev)
-	a := make([]float64, nFactors)
-	b := make([]float64, nFactors)
+	fm.nUserFeatures = featureDim(fm.UserFeatures)
+	fm.nItemFeatures = featureDim(fm.ItemFeatures)
+	nFeatures := trainSet.UserCount + trainSet.ItemCount + fm.nUserFeatures + fm.nItemFeatures
+	fm.GlobalBias = 0
+	fm.Bias = make([]float64, nFeatures)
+	fm.Factor = fm.newNormalMatrix(nFeatures, fm.nFactors, fm.initMean, fm.initStdDev)
+	// Encode ratings
+	samples := make([]SparseVector, trainSet.Length())
+	targets := make([]float64, trainSet.Length())
+	for i := range samples {
+		userId, itemId, rating := trainSet.Index(i)
+		samples[i] = fm.encode(userId, itemId)
+		targets[i] = rating
+		if fm.task == classification {
+			if rating > fm.threshold {
+				targets[i] = 1
+			} else {
+				targets[i] = -1
+			}
+		}
+	}
+	// Create buffers
+	sum := make([]float64, fm.nFactors)
+	// Create updater
+	updater := newUpdater(fm.Params, fm.lr)
+	globalBiasState := updater.newState(1)
+	biasState := updater.newState(nFeatures)
+	factorState := updater.newState(nFeatures * fm.nFactors)
 	// Stochastic Gradient Descent
-	for epoch := 0; epoch < nEpochs; epoch++ {
-		for i := 0; i < set.Length(); i++ {
-			userId, itemId, rating := set.Index(i)
-			innerUserId := set.ConvertUserId(userId)
-			innerItemId := set.ConvertItemId(itemId)
-			//userBias := svd.UserBias[innerUserId]
-			//itemBias := svd.ItemBias[innerItemId]
-			userFactor := fm.UserFactor[innerUserId]
-			itemFactor := fm.ItemFactor[innerItemId]
-			//// Compute error
-			diff := fm.Predict(userId, itemId) - rating
-			//// Update global Bias
-			//gradGlobalBias := diff
-			//fm.GlobalBias -= lr * gradGlobalBias
-			//// Update user Bias
-			//gradUserBias := diff + reg*userBias
-			//fm.UserBias[innerUserId] -= lr * gradUserBias
-			//// Update item Bias
-			//gradItemBias := diff + reg*itemBias
-			//fm.ItemBias[innerItemId] -= lr * gradItemBias
-			// Update user latent factor
-			copy(a, itemFactor)
-			mulConst(diff, a)
-			copy(b, userFactor)
-			mulConst(reg, b)
-			floats.Add(a, b)
-			mulConst(lr, a)
-			floats.Sub(fm.UserFactor[innerUserId], a)
-			// Update item latent factor
-			copy(a, userFactor)
-			mulConst(diff, a)
-			copy(b, itemFactor)
-			mulConst(reg, b)
-			floats.Add(a, b)
-			mulConst(lr, a)
-			floats.Sub(fm.ItemFactor[innerItemId], a)
+	for epoch := 0; epoch < fm.nEpochs; epoch++ {
+		updater.setEpoch(epoch)
+		for i, x := range samples {
+			y := targets[i]
+			// Compute the gradient of loss
+			pred := fm.predict(x)
+			var diff float64
+			if fm.task == classification {
+				diff = (sigmoid(y*pred) - 1) * y
+			} else {
+				diff = pred - y
+			}
+			// Update global bias
+			fm.GlobalBias -= updater.update(globalBiasState, 0, diff)
+			// Update linear weights
+			for j, index := range x.Indices {
+				grad := diff*x.Values[j] + fm.reg*fm.Bias[index]
+				fm.Bias[index] -= updater.update(biasState, index, grad)
+			}
+			// Update pairwise factors
+			resetZeroVector(sum)
+			for j, index := range x.Indices {
+				for f := range sum {
+					sum[f] += fm.Factor[index][f] * x.Values[j]
+				}
+			}
+			for j, index := range x.Indices {
+				xj := x.Values[j]
+				factor := fm.Factor[index]
+				for f := range factor {
+					grad := diff*(xj*sum[f]-factor[f]*xj*xj) + fm.reg*factor[f]
+					factor[f] -= updater.update(factorState, index*fm.nFactors+f, grad)
+				}
+			}
 		}
 	}
 }
+
+// The dimension of a group of side features.
+func featureDim(features map[int]SparseVector) int {
+	dim := 0
+	for _, vec := range features {
+		for _, index := range vec.Indices {
+			if index+1 > dim {
+				dim = index + 1
+			}
+		}
+	}
+	return dim
+}
+
+func sigmoid(x float64) float64 {
+	return 1 / (1 + math.Exp(-x))
+}
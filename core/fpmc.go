@@ -0,0 +1,203 @@
+package core
+
+import (
+	"gonum.org/v1/gonum/floats"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// FPMC: Factorizing Personalized Markov Chains[17]. Ratings of each user are ordered
+// by timestamps and the preference of user u to item i after the last items B is:
+//
+//   \hat{x}_{u,B,i} = <v^{U,I}_u, v^{I,U}_i> + \frac{1}{|B|} \sum_{l \in B} <v^{I,L}_i, v^{L,I}_l>
+//
+// where the first term is matrix factorization and the second term is the factorized
+// item-to-item transition. The model is fitted by S-BPR on transitions in histories of
+// users: the next item should be preferred to a sampled negative given the last items.
+// Ratings are ordered as in the data set if timestamps are not loaded.
+//
+// [17] Rendle, Steffen, Christoph Freudenthaler, and Lars Schmidt-Thieme. "Factorizing
+// personalized markov chains for next-basket recommendation." Proceedings of the 19th
+// international conference on World wide web. ACM, 2010.
+type FPMC struct {
+	Base
+	UserFactor [][]float64 // v^{U,I}_u
+	ItemFactor [][]float64 // v^{I,U}_i
+	NextFactor [][]float64 // v^{I,L}_i
+	LastFactor [][]float64 // v^{L,I}_l
+	LastItems  [][]int     // The last items of each user in the train set
+	// Hyper parameters
+	nFactors   int
+	nEpochs    int
+	nLastItems int
+	lr         float64
+	reg        float64
+	initMean   float64
+	initStdDev float64
+}
+
+// NewFPMC creates a FPMC model. Parameters:
+//	 reg 		- The regularization parameter of the cost function that is
+// 				  optimized. Default is 0.01.
+//	 lr 		- The learning rate of SGD. Default is 0.05.
+//	 nFactors	- The number of latent factors. Default is 64.
+//	 nEpochs	- The number of iteration of the SGD procedure. Default is 20.
+//   nLastItems - The number of last items in a transition. Default is 1.
+//	 initMean	- The mean of initial random latent factors. Default is 0.
+//	 initStdDev	- The standard deviation of initial random latent factors. Default is 0.01.
+//   sampler    - The negative sampler: "uniform", "popularity", "inbatch" or "hard".
+//                Default is "uniform".
+func NewFPMC(params Parameters) *FPMC {
+	fpmc := new(FPMC)
+	fpmc.SetParams(params)
+	return fpmc
+}
+
+// SetParams sets hyper parameters.
+func (fpmc *FPMC) SetParams(params Parameters) {
+	fpmc.Base.SetParams(params)
+	fpmc.nFactors = fpmc.Params.GetInt("nFactors", 64)
+	fpmc.nEpochs = fpmc.Params.GetInt("nEpochs", 20)
+	fpmc.nLastItems = fpmc.Params.GetInt("nLastItems", 1)
+	fpmc.lr = fpmc.Params.GetFloat64("lr", 0.05)
+	fpmc.reg = fpmc.Params.GetFloat64("reg", 0.01)
+	fpmc.initMean = fpmc.Params.GetFloat64("initMean", 0)
+	fpmc.initStdDev = fpmc.Params.GetFloat64("initStdDev", 0.01)
+}
+
+// Predict by a FPMC model. The last items of the user in the train set are used.
+func (fpmc *FPMC) Predict(userId, itemId int) float64 {
+	innerUserId := fpmc.Data.ConvertUserId(userId)
+	innerItemId := fpmc.Data.ConvertItemId(itemId)
+	if innerItemId == NewId {
+		return 0
+	}
+	if innerUserId == NewId {
+		return fpmc.predict(NewId, nil, innerItemId)
+	}
+	return fpmc.predict(innerUserId, fpmc.LastItems[innerUserId], innerItemId)
+}
+
+// Recommend returns top n items (and their scores) for a user after the last items.
+// Items not in the train set are ignored. The user could be new, whose preferences
+// are given by transitions only.
+func (fpmc *FPMC) Recommend(userId int, lastItems []int, n int) ([]int, []float64) {
+	innerUserId := fpmc.Data.ConvertUserId(userId)
+	basket := make([]int, 0, len(lastItems))
+	for _, itemId := range lastItems {
+		if innerItemId := fpmc.Data.ConvertItemId(itemId); innerItemId != NewId {
+			basket = append(basket, innerItemId)
+		}
+	}
+	// Score all items
+	scores := make([]float64, fpmc.Data.ItemCount)
+	order := make([]int, fpmc.Data.ItemCount)
+	for i := range scores {
+		scores[i] = fpmc.predict(innerUserId, basket, i)
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+	if n > len(order) {
+		n = len(order)
+	}
+	items := make([]int, n)
+	itemScores := make([]float64, n)
+	for k, i := range order[:n] {
+		items[k] = fpmc.Data.OuterItemId(i)
+		itemScores[k] = scores[i]
+	}
+	return items, itemScores
+}
+
+func (fpmc *FPMC) predict(innerUserId int, basket []int, innerItemId int) float64 {
+	ret := 0.0
+	// <v^{U,I}_u, v^{I,U}_i>
+	if innerUserId != NewId {
+		ret += floats.Dot(fpmc.UserFactor[innerUserId], fpmc.ItemFactor[innerItemId])
+	}
+	// \frac{1}{|B|} \sum_{l \in B} <v^{I,L}_i, v^{L,I}_l>
+	for _, l := range basket {
+		ret += floats.Dot(fpmc.NextFactor[innerItemId], fpmc.LastFactor[l]) / float64(len(basket))
+	}
+	return ret
+}
+
+// Fit a FPMC model.
+func (fpmc *FPMC) Fit(trainSet TrainSet) {
+	fpmc.Base.Fit(trainSet)
+	// Initialize parameters
+	fpmc.UserFactor = fpmc.newNormalMatrix(trainSet.UserCount, fpmc.nFactors, fpmc.initMean, fpmc.initStdDev)
+	fpmc.ItemFactor = fpmc.newNormalMatrix(trainSet.ItemCount, fpmc.nFactors, fpmc.initMean, fpmc.initStdDev)
+	fpmc.NextFactor = fpmc.newNormalMatrix(trainSet.ItemCount, fpmc.nFactors, fpmc.initMean, fpmc.initStdDev)
+	fpmc.LastFactor = fpmc.newNormalMatrix(trainSet.ItemCount, fpmc.nFactors, fpmc.initMean, fpmc.initStdDev)
+	// Order histories of users by timestamps
+	histories := make([][]int, trainSet.UserCount)
+	for u, indices := range trainSet.UserIndices() {
+		sorted := make([]int, len(indices))
+		copy(sorted, indices)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return trainSet.Timestamp(sorted[i]) < trainSet.Timestamp(sorted[j])
+		})
+		histories[u] = make([]int, len(sorted))
+		for t, index := range sorted {
+			_, itemId, _ := trainSet.Index(index)
+			histories[u][t] = trainSet.ConvertItemId(itemId)
+		}
+	}
+	// Collect transitions <u, t>: the t-th item of user u is the next item of previous items
+	transitions := make([][2]int, 0, trainSet.Length())
+	fpmc.LastItems = make([][]int, trainSet.UserCount)
+	for u, history := range histories {
+		for t := 1; t < len(history); t++ {
+			transitions = append(transitions, [2]int{u, t})
+		}
+		fpmc.LastItems[u] = history[maxInt(0, len(history)-fpmc.nLastItems):]
+	}
+	if len(transitions) == 0 {
+		return
+	}
+	// Optimize by S-BPR
+	rng := rand.New(rand.NewSource(fpmc.rng.Int63()))
+	sampler := NewNegativeSampler(fpmc.Params, fpmc, trainSet, rng.Int63())
+	mean := make([]float64, fpmc.nFactors)
+	for epoch := 0; epoch < fpmc.nEpochs; epoch++ {
+		for k := 0; k < len(transitions); k++ {
+			transition := transitions[rng.Intn(len(transitions))]
+			u, t := transition[0], transition[1]
+			basket := histories[u][maxInt(0, t-fpmc.nLastItems):t]
+			posId := histories[u][t]
+			negId := sampler.Sample(u, posId)
+			if negId < 0 {
+				continue
+			}
+			diff := fpmc.predict(u, basket, posId) - fpmc.predict(u, basket, negId)
+			grad := 1 / (1 + math.Exp(diff))
+			// η = \frac{1}{|B|} \sum_{l \in B} v^{L,I}_l
+			resetZeroVector(mean)
+			for _, l := range basket {
+				floats.AddScaled(mean, 1/float64(len(basket)), fpmc.LastFactor[l])
+			}
+			userFactor := fpmc.UserFactor[u]
+			posFactor, negFactor := fpmc.ItemFactor[posId], fpmc.ItemFactor[negId]
+			posNext, negNext := fpmc.NextFactor[posId], fpmc.NextFactor[negId]
+			for f := 0; f < fpmc.nFactors; f++ {
+				vu, vi, vj := userFactor[f], posFactor[f], negFactor[f]
+				ni, nj := posNext[f], negNext[f]
+				// Update matrix factorization
+				userFactor[f] += fpmc.lr * (grad*(vi-vj) - fpmc.reg*vu)
+				posFactor[f] += fpmc.lr * (grad*vu - fpmc.reg*vi)
+				negFactor[f] += fpmc.lr * (-grad*vu - fpmc.reg*vj)
+				// Update transitions
+				posNext[f] += fpmc.lr * (grad*mean[f] - fpmc.reg*ni)
+				negNext[f] += fpmc.lr * (-grad*mean[f] - fpmc.reg*nj)
+				for _, l := range basket {
+					vl := fpmc.LastFactor[l][f]
+					fpmc.LastFactor[l][f] += fpmc.lr * (grad*(ni-nj)/float64(len(basket)) - fpmc.reg*vl)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"math"
+	"runtime"
+	"sort"
+)
+
+// RP3 recommends items by 3-step random walks on the user-item bipartite graph
+// built from ratings, where rating values are ignored. A walk from user u steps
+// to a rated item i, a user v who rated i and an item j rated by v:
+//
+//   P_{ui} = 1/|R_u|, P_{iv} = 1/|R_i|
+//
+// The transition probabilities from item i to item j are raised to the power of
+// α (P3alpha[19]) and divided by the popularity of item j to the power of β
+// (RP3beta[20]) to penalize popular items:
+//
+//   W_{ij} = \frac{1}{|R_j|^β} \sum_{v \in R_i \cap R_j} P_{iv}^α P_{vj}^α
+//
+// Transitions from each item are kept in a sparse matrix, which could be pruned
+// to the top k transitions. The prediction is the probability of reaching item j
+// from user u:
+//
+//   \hat{x}_{uj} = \sum_{i \in R_u} P_{ui}^α W_{ij}
+//
+// [19] Cooper, Colin, et al. "Random walks in recommender systems: exact computation
+// and simulations." Proceedings of the 23rd International Conference on World Wide
+// Web. ACM, 2014.
+//
+// [20] Paudel, Bibek, et al. "Updatable, accurate, diverse, and scalable
+// recommendations for interactive applications." ACM Transactions on Interactive
+// Intelligent Systems (TiiS) 7.1 (2017): 1.
+type RP3 struct {
+	Base
+	Alpha       float64        // α
+	Beta        float64        // β
+	Transitions []SparseVector // W_{ij} sorted by j
+	UserItems   [][]int        // Items rated by each user
+	// Hyper parameters
+	k     int
+	nJobs int
+}
+
+// NewP3Alpha creates a P3alpha model. Parameters:
+//   alpha - The power of transition probabilities. Default is 1.
+//   beta  - The power of popularity penalization. Default is 0.
+//   k     - The number of transitions kept for each item. Default is 0 (keep all).
+//   nJobs - The number of goroutines to compute transitions. Default is the number of CPUs.
+func NewP3Alpha(params Parameters) *RP3 {
+	rp3 := new(RP3)
+	rp3.SetParams(params)
+	return rp3
+}
+
+// NewRP3Beta creates a RP3beta model. Parameters:
+//   alpha - The power of transition probabilities. Default is 1.
+//   beta  - The power of popularity penalization. Default is 0.5.
+//   k     - The number of transitions kept for each item. Default is 0 (keep all).
+//   nJobs - The number of goroutines to compute transitions. Default is the number of CPUs.
+func NewRP3Beta(params Parameters) *RP3 {
+	rp3 := new(RP3)
+	rp3.Beta = 0.5
+	rp3.SetParams(params)
+	return rp3
+}
+
+// SetParams sets hyper parameters.
+func (rp3 *RP3) SetParams(params Parameters) {
+	rp3.Base.SetParams(params)
+	rp3.Alpha = rp3.Params.GetFloat64("alpha", 1)
+	rp3.Beta = rp3.Params.GetFloat64("beta", rp3.Beta)
+	rp3.k = rp3.Params.GetInt("k", 0)
+	rp3.nJobs = rp3.Params.GetInt("nJobs", runtime.NumCPU())
+}
+
+// Predict by a RP3 model.
+func (rp3 *RP3) Predict(userId, itemId int) float64 {
+	innerUserId := rp3.Data.ConvertUserId(userId)
+	innerItemId := rp3.Data.ConvertItemId(itemId)
+	if innerUserId == NewId || innerItemId == NewId {
+		return 0
+	}
+	ret := 0.0
+	for _, i := range rp3.UserItems[innerUserId] {
+		transition := rp3.Transitions[i]
+		if k := sort.SearchInts(transition.Indices, innerItemId); k < transition.Len() && transition.Indices[k] == innerItemId {
+			ret += transition.Values[k]
+		}
+	}
+	return ret * math.Pow(float64(len(rp3.UserItems[innerUserId])), -rp3.Alpha)
+}
+
+// Recommend returns top n items (and their scores) not rated by a user. Scores
+// are accumulated from transitions of rated items.
+func (rp3 *RP3) Recommend(userId int, n int) ([]int, []float64) {
+	innerUserId := rp3.Data.ConvertUserId(userId)
+	if innerUserId == NewId {
+		return []int{}, []float64{}
+	}
+	userItems := rp3.UserItems[innerUserId]
+	weight := math.Pow(float64(len(userItems)), -rp3.Alpha)
+	scores := make(map[int]float64)
+	for _, i := range userItems {
+		for k, j := range rp3.Transitions[i].Indices {
+			scores[j] += weight * rp3.Transitions[i].Values[k]
+		}
+	}
+	for _, i := range userItems {
+		delete(scores, i)
+	}
+	order := make([]int, 0, len(scores))
+	for j := range scores {
+		order = append(order, j)
+	}
+	sort.Slice(order, func(a, b int) bool {
+		if scores[order[a]] != scores[order[b]] {
+			return scores[order[a]] > scores[order[b]]
+		}
+		return order[a] < order[b]
+	})
+	if n > len(order) {
+		n = len(order)
+	}
+	items := make([]int, n)
+	itemScores := make([]float64, n)
+	for k, j := range order[:n] {
+		items[k] = rp3.Data.OuterItemId(j)
+		itemScores[k] = scores[j]
+	}
+	return items, itemScores
+}
+
+// Fit a RP3 model.
+func (rp3 *RP3) Fit(trainSet TrainSet) {
+	rp3.Base.Fit(trainSet)
+	userRatings := trainSet.UserRatings()
+	itemRatings := trainSet.ItemRatings()
+	rp3.UserItems = make([][]int, trainSet.UserCount)
+	for u, irs := range userRatings {
+		rp3.UserItems[u] = make([]int, len(irs))
+		for k, ir := range irs {
+			rp3.UserItems[u][k] = ir.Id
+		}
+	}
+	// P_{vj}^α and the popularity penalization
+	userWeights := make([]float64, trainSet.UserCount)
+	for v, irs := range userRatings {
+		userWeights[v] = math.Pow(float64(len(irs)), -rp3.Alpha)
+	}
+	penalties := make([]float64, trainSet.ItemCount)
+	for j, irs := range itemRatings {
+		penalties[j] = math.Pow(float64(len(irs)), -rp3.Beta)
+	}
+	// Compute top k transitions of each item
+	rp3.Transitions = make([]SparseVector, trainSet.ItemCount)
+	parallel(trainSet.ItemCount, rp3.nJobs, func(begin, end int) {
+		row := make([]float64, trainSet.ItemCount)
+		for i := begin; i < end; i++ {
+			resetZeroVector(row)
+			itemWeight := math.Pow(float64(len(itemRatings[i])), -rp3.Alpha)
+			for _, vr := range itemRatings[i] {
+				for _, jr := range userRatings[vr.Id] {
+					row[jr.Id] += itemWeight * userWeights[vr.Id]
+				}
+			}
+			row[i] = 0
+			// Select top k transitions
+			candidates := make([]int, 0)
+			for j, w := range row {
+				if w > 0 {
+					row[j] = w * penalties[j]
+					candidates = append(candidates, j)
+				}
+			}
+			sort.Slice(candidates, func(a, b int) bool {
+				if row[candidates[a]] != row[candidates[b]] {
+					return row[candidates[a]] > row[candidates[b]]
+				}
+				return candidates[a] < candidates[b]
+			})
+			if rp3.k > 0 && len(candidates) > rp3.k {
+				candidates = candidates[:rp3.k]
+			}
+			sort.Ints(candidates)
+			rp3.Transitions[i] = SparseVector{Indices: candidates, Values: selectFloat(row, candidates)}
+		}
+	})
+}
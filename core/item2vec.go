@@ -0,0 +1,160 @@
+package core
+
+import (
+	"gonum.org/v1/gonum/floats"
+	"math"
+)
+
+// Item2Vec learns item embeddings by skip-gram with negative sampling[18] where
+// items rated by a user are a sentence. Since the order of items in a set is
+// meaningless, items of each user are shuffled in each epoch and the context
+// of an item is the items in a window around it. For a pair of an item i and a
+// context item c, the objective is:
+//
+//   \log σ(v_i^Tu_c) + \sum_{n \sim P} \log σ(-v_i^Tu_n)
+//
+// where negatives n are sampled from the unigram distribution raised to the
+// power of 0.75. The similarity between items is the cosine similarity between
+// embeddings v_i. A user is represented by the average p_u of embeddings v_j of
+// rated items and the prediction is the score of item i in the context:
+//
+//   \hat{x}_{ui} = p_u^Tu_i
+//
+// Embeddings are learned in O(|R| window nNegatives) time, while the item-based
+// KNN computes similarities between all pairs of items.
+//
+// [18] Barkan, Oren, and Noam Koenigstein. "Item2vec: neural item embedding for
+// collaborative filtering." 2016 IEEE 26th International Workshop on Machine
+// Learning for Signal Processing (MLSP). IEEE, 2016.
+type Item2Vec struct {
+	Base
+	ItemFactor    [][]float64 // v_i
+	ContextFactor [][]float64 // u_c
+	UserFactor    [][]float64 // p_u
+	// Hyper parameters
+	nFactors   int
+	nEpochs    int
+	window     int
+	nNegatives int
+	lr         float64
+	initMean   float64
+	initStdDev float64
+}
+
+// NewItem2Vec creates an item2vec model. Parameters:
+//	 lr 		- The learning rate of SGD. Default is 0.025.
+//	 nFactors	- The number of latent factors. Default is 64.
+//	 nEpochs	- The number of iteration of the SGD procedure. Default is 10.
+//   window     - The number of items on each side of an item in the context. Default is 5.
+//   nNegatives - The number of negatives for each pair. Default is 5.
+//	 initMean	- The mean of initial random latent factors. Default is 0.
+//	 initStdDev	- The standard deviation of initial random latent factors. Default is 0.01.
+func NewItem2Vec(params Parameters) *Item2Vec {
+	i2v := new(Item2Vec)
+	i2v.SetParams(params)
+	return i2v
+}
+
+// SetParams sets hyper parameters.
+func (i2v *Item2Vec) SetParams(params Parameters) {
+	i2v.Base.SetParams(params)
+	i2v.nFactors = i2v.Params.GetInt("nFactors", 64)
+	i2v.nEpochs = i2v.Params.GetInt("nEpochs", 10)
+	i2v.window = i2v.Params.GetInt("window", 5)
+	i2v.nNegatives = i2v.Params.GetInt("nNegatives", 5)
+	i2v.lr = i2v.Params.GetFloat64("lr", 0.025)
+	i2v.initMean = i2v.Params.GetFloat64("initMean", 0)
+	i2v.initStdDev = i2v.Params.GetFloat64("initStdDev", 0.01)
+}
+
+// Predict by an item2vec model.
+func (i2v *Item2Vec) Predict(userId, itemId int) float64 {
+	innerUserId := i2v.Data.ConvertUserId(userId)
+	innerItemId := i2v.Data.ConvertItemId(itemId)
+	if innerUserId == NewId || innerItemId == NewId {
+		return 0
+	}
+	return floats.Dot(i2v.UserFactor[innerUserId], i2v.ContextFactor[innerItemId])
+}
+
+// Similarity returns the cosine similarity between embeddings of two items. Zero
+// is returned if any item is not in the train set.
+func (i2v *Item2Vec) Similarity(itemId1, itemId2 int) float64 {
+	innerItemId1 := i2v.Data.ConvertItemId(itemId1)
+	innerItemId2 := i2v.Data.ConvertItemId(itemId2)
+	if innerItemId1 == NewId || innerItemId2 == NewId {
+		return 0
+	}
+	return cosine(i2v.ItemFactor[innerItemId1], i2v.ItemFactor[innerItemId2])
+}
+
+// Fit an item2vec model.
+func (i2v *Item2Vec) Fit(trainSet TrainSet) {
+	i2v.Base.Fit(trainSet)
+	// Initialize parameters
+	i2v.ItemFactor = i2v.newNormalMatrix(trainSet.ItemCount, i2v.nFactors, i2v.initMean, i2v.initStdDev)
+	i2v.ContextFactor = newZeroMatrix(trainSet.ItemCount, i2v.nFactors)
+	// P(n) \propto |R_n|^{0.75}
+	weights := make([]float64, trainSet.ItemCount)
+	for i, irs := range trainSet.ItemRatings() {
+		weights[i] = math.Pow(float64(len(irs)), 0.75)
+	}
+	table := newAliasTable(weights)
+	// Create sentences
+	sentences := make([][]int, trainSet.UserCount)
+	for u, irs := range trainSet.UserRatings() {
+		sentences[u] = make([]int, len(irs))
+		for j, ir := range irs {
+			sentences[u][j] = ir.Id
+		}
+	}
+	// Skip-gram with negative sampling
+	grad := make([]float64, i2v.nFactors)
+	for epoch := 0; epoch < i2v.nEpochs; epoch++ {
+		for _, u := range i2v.rng.Perm(len(sentences)) {
+			sentence := sentences[u]
+			i2v.rng.Shuffle(len(sentence), func(i, j int) {
+				sentence[i], sentence[j] = sentence[j], sentence[i]
+			})
+			for t, target := range sentence {
+				for c := maxInt(0, t-i2v.window); c <= t+i2v.window && c < len(sentence); c++ {
+					if c == t {
+						continue
+					}
+					itemFactor := i2v.ItemFactor[target]
+					resetZeroVector(grad)
+					for k := 0; k <= i2v.nNegatives; k++ {
+						// The first sample is the context and others are negatives
+						sample, label := sentence[c], 1.0
+						if k > 0 {
+							if sample, label = table.sample(i2v.rng), 0; sample == sentence[c] {
+								continue
+							}
+						}
+						contextFactor := i2v.ContextFactor[sample]
+						g := i2v.lr * (label - sigmoid(floats.Dot(itemFactor, contextFactor)))
+						floats.AddScaled(grad, g, contextFactor)
+						floats.AddScaled(contextFactor, g, itemFactor)
+					}
+					floats.Add(itemFactor, grad)
+				}
+			}
+		}
+	}
+	// Average embeddings of rated items
+	i2v.UserFactor = newZeroMatrix(trainSet.UserCount, i2v.nFactors)
+	for u, sentence := range sentences {
+		for _, i := range sentence {
+			floats.AddScaled(i2v.UserFactor[u], 1/float64(len(sentence)), i2v.ItemFactor[i])
+		}
+	}
+}
+
+// Cosine similarity between two dense vectors.
+func cosine(a, b []float64) float64 {
+	normA, normB := floats.Norm(a, 2), floats.Norm(b, 2)
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return floats.Dot(a, b) / (normA * normB)
+}
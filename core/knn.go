@@ -28,7 +28,8 @@ const (
 )
 
 // NewKNN creates a KNN model. Parameters:
-//   sim       - The similarity function. Default is MSD.
+//   sim       - The similarity function: Similarity or BaselineSimilarity. Default is MSD.
+//   shrinkage - The shrinkage parameter of BaselineSimilarity. Default is 100.
 //   userBased - User based or item based? Default is true.
 //   k         - The maximum k neighborhoods to predict the rating. Default is 40.
 //   minK      - The minimum k neighborhoods to predict the rating. Default is 1.
@@ -41,7 +42,8 @@ func NewKNN(params Parameters) *KNN {
 }
 
 // NewKNNWithMean creates a KNN model with Mean. Parameters:
-//   sim       - The similarity function. Default is MSD.
+//   sim       - The similarity function: Similarity or BaselineSimilarity. Default is MSD.
+//   shrinkage - The shrinkage parameter of BaselineSimilarity. Default is 100.
 //   userBased - User based or item based? Default is true.
 //   k         - The maximum k neighborhoods to predict the rating. Default is 40.
 //   minK      - The minimum k neighborhoods to predict the rating. Default is 1.
@@ -54,7 +56,8 @@ func NewKNNWithMean(params Parameters) *KNN {
 }
 
 // NewKNNWithZScore creates a KNN model with Z-Score. Parameters:
-//   sim       - The similarity function. Default is MSD.
+//   sim       - The similarity function: Similarity or BaselineSimilarity. Default is MSD.
+//   shrinkage - The shrinkage parameter of BaselineSimilarity. Default is 100.
 //   userBased - User based or item based? Default is true.
 //   k         - The maximum k neighborhoods to predict the rating. Default is 40.
 //   minK      - The minimum k neighborhoods to predict the rating. Default is 1.
@@ -67,7 +70,8 @@ func NewKNNWithZScore(params Parameters) *KNN {
 }
 
 // NewKNNBaseLine creates a KNN model with baseline. Parameters:
-//   sim       - The similarity function. Default is MSD.
+//   sim       - The similarity function: Similarity or BaselineSimilarity. Default is MSD.
+//   shrinkage - The shrinkage parameter of BaselineSimilarity. Default is 100.
 //   userBased - User based or item based? Default is true.
 //   k         - The maximum k neighborhoods to predict the rating. Default is 40.
 //   minK      - The minimum k neighborhoods to predict the rating. Default is 1.
@@ -116,10 +120,15 @@ func (knn *KNN) Predict(userId, itemId int) float64 {
 	if numNeighbors > candidateSet.Len() {
 		numNeighbors = candidateSet.Len()
 	}
-	// Predict the rating by weighted GlobalMean
+	// Predict the rating by weighted GlobalMean. Baseline similarities could be negative,
+	// so only neighbors with positive similarities are used.
+	positiveOnly := isBaselineSim(knn.Params["sim"])
 	weightSum := 0.0
 	weightRating := 0.0
 	for _, or := range candidateSet.candidates[0:numNeighbors] {
+		if positiveOnly && knn.Sims[leftId][or.Id] <= 0 {
+			continue
+		}
 		weightSum += knn.Sims[leftId][or.Id]
 		rating := or.Rating
 		if knn.KNNType == centered {
@@ -132,6 +141,13 @@ func (knn *KNN) Predict(userId, itemId int) float64 {
 		weightRating += knn.Sims[leftId][or.Id] * rating
 	}
 	prediction := weightRating / weightSum
+	if positiveOnly && weightSum == 0 {
+		// No positive neighbors
+		if knn.KNNType == basic {
+			return knn.GlobalMean
+		}
+		prediction = 0
+	}
 	if knn.KNNType == centered {
 		prediction += knn.Means[leftId]
 	} else if knn.KNNType == zScore {
@@ -146,21 +162,98 @@ func (knn *KNN) Predict(userId, itemId int) float64 {
 // Fit a KNN model.
 func (knn *KNN) Fit(trainSet TrainSet) {
 	knn.Base.Fit(trainSet)
-	// Setup parameters
-	sim := knn.Params.GetSim("sim", MSD)
+	nJobs := knn.Params.GetInt("nJobs", runtime.NumCPU())
+	sortedLeftRatings, sim := knn.fitStatistics(trainSet)
+	knn.Sims = newNanMatrix(len(sortedLeftRatings), len(sortedLeftRatings))
+	// Pairwise similarity
+	parallel(len(sortedLeftRatings), nJobs, func(begin, end int) {
+		for iId := begin; iId < end; iId++ {
+			iRatings := sortedLeftRatings[iId]
+			for jId, jRatings := range sortedLeftRatings {
+				if iId != jId {
+					if math.IsNaN(knn.Sims[iId][jId]) {
+						ret := sim(iRatings, jRatings)
+						if !math.IsNaN(ret) {
+							knn.Sims[iId][jId] = ret
+							knn.Sims[jId][iId] = ret
+						}
+					}
+				}
+			}
+		}
+	})
+}
+
+// PartialFit updates a fitted KNN model with new ratings. Means and standard
+// deviations of users (items) only depend on their own ratings, so only similarities
+// between users (items) with new ratings and others are computed again. Baselines
+// depend on all ratings, so the model is fitted again on all ratings if baselines
+// are used by predictions or similarities.
+func (knn *KNN) PartialFit(dataSet DataSet) {
 	userBased := knn.Params.GetBool("userBased", true)
 	nJobs := knn.Params.GetInt("nJobs", runtime.NumCPU())
+	delta := knn.Data.Append(dataSet)
+	if knn.KNNType == baseline || isBaselineSim(knn.Params["sim"]) {
+		knn.Fit(knn.Data)
+		return
+	}
+	sortedLeftRatings, sim := knn.fitStatistics(knn.Data)
+	// Grow the similarity matrix
+	nLeft := len(sortedLeftRatings)
+	for i := range knn.Sims {
+		knn.Sims[i] = append(knn.Sims[i], newNanVector(nLeft-len(knn.Sims[i]))...)
+	}
+	knn.Sims = append(knn.Sims, newNanMatrix(nLeft-len(knn.Sims), nLeft)...)
+	// Find users (items) with new ratings
+	updated := make([]int, 0)
+	isUpdated := make(map[int]bool)
+	delta.ForEach(func(userId, itemId int, rating float64) {
+		leftId := delta.ConvertItemId(itemId)
+		if userBased {
+			leftId = delta.ConvertUserId(userId)
+		}
+		if !isUpdated[leftId] {
+			isUpdated[leftId] = true
+			updated = append(updated, leftId)
+		}
+	})
+	// Update similarity
+	parallel(len(updated), nJobs, func(begin, end int) {
+		for _, iId := range updated[begin:end] {
+			iRatings := sortedLeftRatings[iId]
+			for jId, jRatings := range sortedLeftRatings {
+				if iId != jId {
+					ret := sim(iRatings, jRatings)
+					knn.Sims[iId][jId] = ret
+					knn.Sims[jId][iId] = ret
+				}
+			}
+		}
+	})
+}
+
+// Compute statistics of users (items) on a train set. Sorted ratings of users
+// (items) and the similarity function are returned.
+func (knn *KNN) fitStatistics(trainSet TrainSet) ([]SortedIdRatings, Similarity) {
+	// Setup parameters
+	var sim Similarity
+	var baselineSim BaselineSimilarity
+	if isBaselineSim(knn.Params["sim"]) {
+		baselineSim = knn.Params.GetBaselineSim("sim", nil)
+	} else {
+		sim = knn.Params.GetSim("sim", MSD)
+	}
+	shrinkage := knn.Params.GetInt("shrinkage", 100)
+	userBased := knn.Params.GetBool("userBased", true)
 	// Set global GlobalMean for new users (items)
 	knn.GlobalMean = trainSet.GlobalMean
 	// Retrieve user (item) iRatings
 	if userBased {
 		knn.LeftRatings = trainSet.UserRatings()
 		knn.RightRatings = trainSet.ItemRatings()
-		knn.Sims = newNanMatrix(trainSet.UserCount, trainSet.UserCount)
 	} else {
 		knn.LeftRatings = trainSet.ItemRatings()
 		knn.RightRatings = trainSet.UserRatings()
-		knn.Sims = newNanMatrix(trainSet.ItemCount, trainSet.ItemCount)
 	}
 	// Retrieve user (item) Mean
 	if knn.KNNType == centered || knn.KNNType == zScore {
@@ -178,33 +271,44 @@ func (knn *KNN) Fit(trainSet TrainSet) {
 			knn.StdDevs[i] = math.Sqrt(sum/count) + 1e-5
 		}
 	}
-	if knn.KNNType == baseline {
+	// Retrieve baselines
+	var leftBias, rightBias []float64
+	var globalBias float64
+	if knn.KNNType == baseline || baselineSim != nil {
 		baseLine := NewBaseLine(knn.Params)
 		baseLine.Fit(trainSet)
+		globalBias = baseLine.GlobalBias
 		if userBased {
-			knn.Bias = baseLine.UserBias
+			leftBias, rightBias = baseLine.UserBias, baseLine.ItemBias
 		} else {
-			knn.Bias = baseLine.ItemBias
+			leftBias, rightBias = baseLine.ItemBias, baseLine.UserBias
 		}
 	}
-	// Pairwise similarity
+	if knn.KNNType == baseline {
+		knn.Bias = leftBias
+	}
+	// Sort ratings of users (items)
 	sortedLeftRatings := sorts(knn.LeftRatings)
-	parallel(len(sortedLeftRatings), nJobs, func(begin, end int) {
-		for iId := begin; iId < end; iId++ {
-			iRatings := sortedLeftRatings[iId]
-			for jId, jRatings := range sortedLeftRatings {
-				if iId != jId {
-					if math.IsNaN(knn.Sims[iId][jId]) {
-						ret := sim(iRatings, jRatings)
-						if !math.IsNaN(ret) {
-							knn.Sims[iId][jId] = ret
-							knn.Sims[jId][iId] = ret
-						}
-					}
-				}
-			}
+	if baselineSim != nil {
+		// Compute similarity on residuals
+		sortedLeftRatings = sorts(residuals(knn.LeftRatings, globalBias, leftBias, rightBias))
+		sim = func(a SortedIdRatings, b SortedIdRatings) float64 {
+			return baselineSim(a, b, shrinkage)
 		}
-	})
+	}
+	return sortedLeftRatings, sim
+}
+
+// Remove baselines b_{ui} = μ + b_u + b_i from ratings of users (items).
+func residuals(leftRatings [][]IdRating, globalBias float64, leftBias, rightBias []float64) [][]IdRating {
+	ret := make([][]IdRating, len(leftRatings))
+	for i, irs := range leftRatings {
+		ret[i] = make([]IdRating, len(irs))
+		for j, ir := range irs {
+			ret[i][j] = IdRating{ir.Id, ir.Rating - globalBias - leftBias[i] - rightBias[ir.Id]}
+		}
+	}
+	return ret
 }
 
 // A data structure used to sort candidates by similarity.
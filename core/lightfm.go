@@ -0,0 +1,247 @@
+package core
+
+import (
+	"gonum.org/v1/gonum/floats"
+)
+
+// LightFM is a hybrid factor model[16] where users and items are represented as
+// sums of embeddings of their features. The ID of a user (item) in the train set
+// is one of its features. Given the feature vector x_u of user u and the feature
+// vector x_i of item i, the prediction is:
+//
+//   \hat{r}_{ui} = μ + \sum_a x_{u,a} b^U_a + \sum_b x_{i,b} b^I_b + p_u^Tq_i
+//
+// where p_u = \sum_a x_{u,a} e^U_a and q_i = \sum_b x_{i,b} e^I_b. Side features are
+// optional and should be set to UserFeatures and ItemFeatures before fitting. New
+// users (items) are represented by their side features only, so that predictions
+// for cold users and items are still personalized.
+//
+// [16] Kula, Maciej. "Metadata embeddings for user and item cold-start recommendations."
+// Proceedings of the 2nd Workshop on New Trends on Content-Based Recommender Systems.
+// 2015.
+type LightFM struct {
+	Base
+	// Side features
+	UserFeatures map[int]SparseVector // userId -> features
+	ItemFeatures map[int]SparseVector // itemId -> features
+	// Model parameters
+	GlobalBias float64     // μ
+	UserBias   []float64   // b^U_a
+	ItemBias   []float64   // b^I_b
+	UserFactor [][]float64 // e^U_a
+	ItemFactor [][]float64 // e^I_b
+	// Hyper parameters
+	nFactors   int
+	nEpochs    int
+	lr         float64
+	reg        float64
+	initMean   float64
+	initStdDev float64
+	optimizer  Optimizer
+	// Feature space
+	nUserFeatures int
+	nItemFeatures int
+	userEncodings []SparseVector // x_u of users in the train set
+	itemEncodings []SparseVector // x_i of items in the train set
+	// Optimization
+	updater         *updater
+	globalBiasState *updaterState
+	userBiasState   *updaterState
+	itemBiasState   *updaterState
+	userFactorState *updaterState
+	itemFactorState *updaterState
+}
+
+// NewLightFM creates a LightFM model. Parameters:
+//	 reg 		- The regularization parameter of the cost function that is
+// 				  optimized. Default is 0.02.
+//	 lr 		- The learning rate of SGD. Default is 0.05.
+//	 nFactors	- The number of latent factors. Default is 10.
+//	 nEpochs	- The number of iteration of the SGD procedure. Default is 20.
+//	 initMean	- The mean of initial random latent factors. Default is 0.
+//	 initStdDev	- The standard deviation of initial random latent factors. Default is 0.1.
+//   optimizer  - The optimizer to optimize model parameters: SGDOptimizer, BPROptimizer,
+//                LogisticOptimizer or WARPOptimizer. Default is LogisticOptimizer.
+//   updater    - The per-parameter updater: "sgd", "adagrad", "rmsprop" or "adam". Default is "sgd".
+//   sampler    - The negative sampler of BPROptimizer and LogisticOptimizer: "uniform",
+//                "popularity", "inbatch" or "hard". Default is "uniform".
+func NewLightFM(params Parameters) *LightFM {
+	lfm := new(LightFM)
+	lfm.SetParams(params)
+	return lfm
+}
+
+// SetParams sets hyper parameters.
+func (lfm *LightFM) SetParams(params Parameters) {
+	lfm.Base.SetParams(params)
+	lfm.nFactors = lfm.Params.GetInt("nFactors", 10)
+	lfm.nEpochs = lfm.Params.GetInt("nEpochs", 20)
+	lfm.lr = lfm.Params.GetFloat64("lr", 0.05)
+	lfm.reg = lfm.Params.GetFloat64("reg", 0.02)
+	lfm.initMean = lfm.Params.GetFloat64("initMean", 0)
+	lfm.initStdDev = lfm.Params.GetFloat64("initStdDev", 0.1)
+	lfm.optimizer = lfm.Params.GetOptimizer("optimizer", LogisticOptimizer)
+}
+
+// Predict by a LightFM model.
+func (lfm *LightFM) Predict(userId, itemId int) float64 {
+	return lfm.predict(lfm.encodeUser(userId), lfm.encodeItem(itemId))
+}
+
+func (lfm *LightFM) predict(userFeatures, itemFeatures SparseVector) float64 {
+	ret := lfm.GlobalBias
+	// + \sum_a x_{u,a} b^U_a + \sum_b x_{i,b} b^I_b
+	for i, index := range userFeatures.Indices {
+		ret += userFeatures.Values[i] * lfm.UserBias[index]
+	}
+	for i, index := range itemFeatures.Indices {
+		ret += itemFeatures.Values[i] * lfm.ItemBias[index]
+	}
+	// + p_u^Tq_i
+	userFactor := embed(userFeatures, lfm.UserFactor, lfm.nFactors)
+	itemFactor := embed(itemFeatures, lfm.ItemFactor, lfm.nFactors)
+	return ret + floats.Dot(userFactor, itemFactor)
+}
+
+// Encode a user to a feature vector. The feature space is:
+//
+//   [users | user features]
+//
+func (lfm *LightFM) encodeUser(userId int) SparseVector {
+	x := SparseVector{}
+	if innerUserId := lfm.Data.ConvertUserId(userId); innerUserId != NewId {
+		x.Add(innerUserId, 1)
+	}
+	if features, exist := lfm.UserFeatures[userId]; exist {
+		for i, index := range features.Indices {
+			if index < lfm.nUserFeatures {
+				x.Add(lfm.Data.UserCount+index, features.Values[i])
+			}
+		}
+	}
+	return x
+}
+
+// Encode an item to a feature vector. The feature space is:
+//
+//   [items | item features]
+//
+func (lfm *LightFM) encodeItem(itemId int) SparseVector {
+	x := SparseVector{}
+	if innerItemId := lfm.Data.ConvertItemId(itemId); innerItemId != NewId {
+		x.Add(innerItemId, 1)
+	}
+	if features, exist := lfm.ItemFeatures[itemId]; exist {
+		for i, index := range features.Indices {
+			if index < lfm.nItemFeatures {
+				x.Add(lfm.Data.ItemCount+index, features.Values[i])
+			}
+		}
+	}
+	return x
+}
+
+// Sum embeddings of features.
+func embed(x SparseVector, factors [][]float64, nFactors int) []float64 {
+	ret := make([]float64, nFactors)
+	for i, index := range x.Indices {
+		floats.AddScaled(ret, x.Values[i], factors[index])
+	}
+	return ret
+}
+
+// Fit a LightFM model.
+func (lfm *LightFM) Fit(trainSet TrainSet) {
+	lfm.Base.Fit(trainSet)
+	// Initialize parameters
+	lfm.nUserFeatures = featureDim(lfm.UserFeatures)
+	lfm.nItemFeatures = featureDim(lfm.ItemFeatures)
+	nUserFeatures := trainSet.UserCount + lfm.nUserFeatures
+	nItemFeatures := trainSet.ItemCount + lfm.nItemFeatures
+	lfm.GlobalBias = 0
+	lfm.UserBias = make([]float64, nUserFeatures)
+	lfm.ItemBias = make([]float64, nItemFeatures)
+	lfm.UserFactor = lfm.newNormalMatrix(nUserFeatures, lfm.nFactors, lfm.initMean, lfm.initStdDev)
+	lfm.ItemFactor = lfm.newNormalMatrix(nItemFeatures, lfm.nFactors, lfm.initMean, lfm.initStdDev)
+	// Encode users and items
+	lfm.userEncodings = make([]SparseVector, trainSet.UserCount)
+	for u := range lfm.userEncodings {
+		lfm.userEncodings[u] = lfm.encodeUser(trainSet.OuterUserId(u))
+	}
+	lfm.itemEncodings = make([]SparseVector, trainSet.ItemCount)
+	for i := range lfm.itemEncodings {
+		lfm.itemEncodings[i] = lfm.encodeItem(trainSet.OuterItemId(i))
+	}
+	// Create updater
+	lfm.updater = newUpdater(lfm.Params, lfm.lr)
+	lfm.globalBiasState = lfm.updater.newState(1)
+	lfm.userBiasState = lfm.updater.newState(nUserFeatures)
+	lfm.itemBiasState = lfm.updater.newState(nItemFeatures)
+	lfm.userFactorState = lfm.updater.newState(nUserFeatures * lfm.nFactors)
+	lfm.itemFactorState = lfm.updater.newState(nItemFeatures * lfm.nFactors)
+	// Optimize
+	lfm.optimizer(lfm, trainSet, lfm.nEpochs)
+}
+
+// StartEpoch updates the learning rate by the schedule.
+func (lfm *LightFM) StartEpoch(epoch int) {
+	lfm.updater.setEpoch(epoch)
+}
+
+// PointUpdate updates model parameters by point.
+func (lfm *LightFM) PointUpdate(upGrad float64, innerUserId, innerItemId int) {
+	userFeatures := lfm.userEncodings[innerUserId]
+	itemFeatures := lfm.itemEncodings[innerItemId]
+	userFactor := embed(userFeatures, lfm.UserFactor, lfm.nFactors)
+	itemFactor := embed(itemFeatures, lfm.ItemFactor, lfm.nFactors)
+	// Update global bias
+	lfm.GlobalBias += lfm.updater.update(lfm.globalBiasState, 0, upGrad)
+	// Update embeddings of user features: x_{u,a} q_i
+	for i, index := range userFeatures.Indices {
+		x := userFeatures.Values[i]
+		lfm.UserBias[index] += lfm.updater.update(lfm.userBiasState, index, upGrad*x-lfm.reg*lfm.UserBias[index])
+		lfm.updateFactor(lfm.UserFactor[index], lfm.userFactorState, index, upGrad*x, itemFactor)
+	}
+	// Update embeddings of item features: x_{i,b} p_u
+	for i, index := range itemFeatures.Indices {
+		x := itemFeatures.Values[i]
+		lfm.ItemBias[index] += lfm.updater.update(lfm.itemBiasState, index, upGrad*x-lfm.reg*lfm.ItemBias[index])
+		lfm.updateFactor(lfm.ItemFactor[index], lfm.itemFactorState, index, upGrad*x, userFactor)
+	}
+}
+
+// PairUpdate updates model parameters by pair.
+func (lfm *LightFM) PairUpdate(upGrad float64, innerUserId, positiveItemId, negativeItemId int) {
+	userFeatures := lfm.userEncodings[innerUserId]
+	positiveFeatures := lfm.itemEncodings[positiveItemId]
+	negativeFeatures := lfm.itemEncodings[negativeItemId]
+	userFactor := embed(userFeatures, lfm.UserFactor, lfm.nFactors)
+	positiveFactor := embed(positiveFeatures, lfm.ItemFactor, lfm.nFactors)
+	negativeFactor := embed(negativeFeatures, lfm.ItemFactor, lfm.nFactors)
+	// Update embeddings of user features: x_{u,a} (q_i - q_j)
+	diffFactor := make([]float64, lfm.nFactors)
+	floats.SubTo(diffFactor, positiveFactor, negativeFactor)
+	for i, index := range userFeatures.Indices {
+		lfm.updateFactor(lfm.UserFactor[index], lfm.userFactorState, index, upGrad*userFeatures.Values[i], diffFactor)
+	}
+	// Update embeddings of positive item features: x_{i,b} p_u
+	for i, index := range positiveFeatures.Indices {
+		x := positiveFeatures.Values[i]
+		lfm.ItemBias[index] += lfm.updater.update(lfm.itemBiasState, index, upGrad*x-lfm.reg*lfm.ItemBias[index])
+		lfm.updateFactor(lfm.ItemFactor[index], lfm.itemFactorState, index, upGrad*x, userFactor)
+	}
+	// Update embeddings of negative item features: -x_{j,b} p_u
+	for i, index := range negativeFeatures.Indices {
+		x := negativeFeatures.Values[i]
+		lfm.ItemBias[index] += lfm.updater.update(lfm.itemBiasState, index, -upGrad*x-lfm.reg*lfm.ItemBias[index])
+		lfm.updateFactor(lfm.ItemFactor[index], lfm.itemFactorState, index, -upGrad*x, userFactor)
+	}
+}
+
+// Update the embedding of a feature given the scaled gradient and the factor of the other side.
+func (lfm *LightFM) updateFactor(factor []float64, state *updaterState, index int, upGrad float64, other []float64) {
+	offset := index * lfm.nFactors
+	for f := range factor {
+		factor[f] += lfm.updater.update(state, offset+f, upGrad*other[f]-lfm.reg*factor[f])
+	}
+}
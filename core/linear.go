@@ -0,0 +1,242 @@
+package core
+
+import (
+	"gonum.org/v1/gonum/mat"
+	"runtime"
+	"sort"
+)
+
+/* EASE */
+
+// EASE: Embarrassingly Shallow Autoencoders for sparse data[11]. EASE learns
+// an item-item weight matrix B with zero diagonal from the binary user-item
+// matrix X. The closed-form solution of
+//
+//   \min_B ||X - XB||^2_F + λ||B||^2_F \quad s.t. \quad diag(B) = 0
+//
+// is B_{ij} = -P_{ij}/P_{jj} (i≠j) where P = (X^TX + λI)^{-1}. The score of
+// item j for user u is \sum_{i \in I_u} B_{ij}.
+type EASE struct {
+	Base
+	UserRatings [][]IdRating   // I_u sorted by ID
+	Weights     []SparseVector // B_{·j}
+}
+
+// NewEASE creates an EASE model. Parameters:
+//	 reg 		- The regularization parameter. Default is 500.
+//	 topK		- The number of weights kept for each item. Default is 0 (keep all).
+func NewEASE(params Parameters) *EASE {
+	ease := new(EASE)
+	ease.Params = params
+	return ease
+}
+
+// Predict by an EASE model.
+func (ease *EASE) Predict(userId, itemId int) float64 {
+	innerUserId := ease.Data.ConvertUserId(userId)
+	innerItemId := ease.Data.ConvertItemId(itemId)
+	if innerUserId != NewId && innerItemId != NewId {
+		return itemItemScore(ease.UserRatings[innerUserId], ease.Weights[innerItemId])
+	}
+	return 0
+}
+
+// Fit an EASE model.
+func (ease *EASE) Fit(trainSet TrainSet) {
+	ease.Base.Fit(trainSet)
+	// Setup parameters
+	reg := ease.Params.GetFloat64("reg", 500)
+	topK := ease.Params.GetInt("topK", 0)
+	ease.UserRatings = sortByIds(trainSet.UserRatings())
+	// G = X^TX + λI
+	g := mat.NewSymDense(trainSet.ItemCount, nil)
+	for _, irs := range ease.UserRatings {
+		for _, ir := range irs {
+			for _, jr := range irs {
+				if ir.Id <= jr.Id {
+					g.SetSym(ir.Id, jr.Id, g.At(ir.Id, jr.Id)+1)
+				}
+			}
+		}
+	}
+	for i := 0; i < trainSet.ItemCount; i++ {
+		g.SetSym(i, i, g.At(i, i)+reg)
+	}
+	// P = G^{-1}
+	var chol mat.Cholesky
+	if !chol.Factorize(g) {
+		panic("EASE: X^TX + λI is not positive definite")
+	}
+	p := mat.NewSymDense(trainSet.ItemCount, nil)
+	chol.InverseTo(p)
+	// B_{ij} = -P_{ij}/P_{jj}
+	ease.Weights = make([]SparseVector, trainSet.ItemCount)
+	for j := range ease.Weights {
+		for i := 0; i < trainSet.ItemCount; i++ {
+			if i != j {
+				if weight := -p.At(i, j) / p.At(j, j); weight != 0 {
+					ease.Weights[j].Add(i, weight)
+				}
+			}
+		}
+		ease.Weights[j] = topWeights(ease.Weights[j], topK)
+	}
+}
+
+/* SLIM */
+
+// SLIM: Sparse Linear Methods for top-N recommender systems[12]. SLIM learns a
+// sparse non-negative item-item weight matrix W with zero diagonal from the
+// binary user-item matrix X by solving an elastic net problem for each item j:
+//
+//   \min_{w_j} \frac{1}{2}||x_j - Xw_j||^2 + β_1||w_j||_1 + \frac{β_2}{2}||w_j||^2 \quad s.t. \quad w_j \ge 0, w_{jj} = 0
+//
+// The problems are solved by coordinate descent. The score of item j for user u
+// is \sum_{i \in I_u} W_{ij}.
+type SLIM struct {
+	Base
+	UserRatings [][]IdRating   // I_u sorted by ID
+	Weights     []SparseVector // W_{·j}
+}
+
+// NewSLIM creates a SLIM model. Parameters:
+//	 l1Reg 		- The L1 regularization parameter. Default is 1.
+//	 l2Reg 		- The L2 regularization parameter. Default is 1.
+//	 nEpochs	- The number of iteration of the coordinate descent procedure. Default is 10.
+//	 topK		- The number of weights kept for each item. Default is 0 (keep all).
+//	 nJobs		- The number of goroutines to learn weights. Default is the number of CPUs.
+func NewSLIM(params Parameters) *SLIM {
+	slim := new(SLIM)
+	slim.Params = params
+	return slim
+}
+
+// Predict by a SLIM model.
+func (slim *SLIM) Predict(userId, itemId int) float64 {
+	innerUserId := slim.Data.ConvertUserId(userId)
+	innerItemId := slim.Data.ConvertItemId(itemId)
+	if innerUserId != NewId && innerItemId != NewId {
+		return itemItemScore(slim.UserRatings[innerUserId], slim.Weights[innerItemId])
+	}
+	return 0
+}
+
+// Fit a SLIM model.
+func (slim *SLIM) Fit(trainSet TrainSet) {
+	slim.Base.Fit(trainSet)
+	// Setup parameters
+	l1Reg := slim.Params.GetFloat64("l1Reg", 1)
+	l2Reg := slim.Params.GetFloat64("l2Reg", 1)
+	nEpochs := slim.Params.GetInt("nEpochs", 10)
+	topK := slim.Params.GetInt("topK", 0)
+	nJobs := slim.Params.GetInt("nJobs", runtime.NumCPU())
+	slim.UserRatings = sortByIds(trainSet.UserRatings())
+	itemRatings := trainSet.ItemRatings()
+	// Learn weights of each item
+	slim.Weights = make([]SparseVector, trainSet.ItemCount)
+	parallel(trainSet.ItemCount, nJobs, func(begin, end int) {
+		residual := make([]float64, trainSet.UserCount)
+		weights := make([]float64, trainSet.ItemCount)
+		for j := begin; j < end; j++ {
+			// Items co-rated with item j, only whose weights could be positive
+			candidates := make(map[int]bool)
+			for _, ur := range itemRatings[j] {
+				for _, ir := range slim.UserRatings[ur.Id] {
+					if ir.Id != j {
+						candidates[ir.Id] = true
+					}
+				}
+			}
+			coordinates := make([]int, 0, len(candidates))
+			for i := range candidates {
+				coordinates = append(coordinates, i)
+			}
+			sort.Ints(coordinates)
+			// r = x_j - Xw_j
+			resetZeroVector(residual)
+			for _, ur := range itemRatings[j] {
+				residual[ur.Id] = 1
+			}
+			// Coordinate descent
+			for epoch := 0; epoch < nEpochs; epoch++ {
+				for _, i := range coordinates {
+					// ρ = x_i^Tr + ||x_i||^2w_{ij}
+					norm := float64(len(itemRatings[i]))
+					rho := norm * weights[i]
+					for _, ur := range itemRatings[i] {
+						rho += residual[ur.Id]
+					}
+					// Soft-thresholding with non-negative constraint
+					weight := 0.0
+					if rho > l1Reg {
+						weight = (rho - l1Reg) / (norm + l2Reg)
+					}
+					// Update residual
+					if delta := weight - weights[i]; delta != 0 {
+						for _, ur := range itemRatings[i] {
+							residual[ur.Id] -= delta
+						}
+						weights[i] = weight
+					}
+				}
+			}
+			// Save non-zero weights
+			for _, i := range coordinates {
+				if weights[i] > 0 {
+					slim.Weights[j].Add(i, weights[i])
+				}
+				weights[i] = 0
+			}
+			slim.Weights[j] = topWeights(slim.Weights[j], topK)
+		}
+	})
+}
+
+/* Utils */
+
+// Compute \sum_{i \in I_u} w_i, where both I_u and w are sorted by ID.
+func itemItemScore(userRatings []IdRating, weights SparseVector) float64 {
+	sum, ptr := 0.0, 0
+	for _, ir := range userRatings {
+		for ptr < weights.Len() && weights.Indices[ptr] < ir.Id {
+			ptr++
+		}
+		if ptr < weights.Len() && weights.Indices[ptr] == ir.Id {
+			sum += weights.Values[ptr]
+		}
+	}
+	return sum
+}
+
+// Copy <id, rating>s of each user (item) sorted by ID.
+func sortByIds(idRatings [][]IdRating) [][]IdRating {
+	ret := make([][]IdRating, len(idRatings))
+	for i, irs := range idRatings {
+		ret[i] = append([]IdRating(nil), irs...)
+		sort.Sort(SortedIdRatings{ret[i]})
+	}
+	return ret
+}
+
+// Keep the top k weights sorted by ID. All weights are kept if k is 0.
+func topWeights(weights SparseVector, k int) SparseVector {
+	if k <= 0 || weights.Len() <= k {
+		return weights
+	}
+	// Find top k weights
+	order := make([]int, weights.Len())
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return weights.Values[order[i]] > weights.Values[order[j]]
+	})
+	order = order[:k]
+	sort.Ints(order)
+	// Collect top k weights
+	ret := SparseVector{}
+	for _, i := range order {
+		ret.Add(weights.Indices[i], weights.Values[i])
+	}
+	return ret
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"math"
 	"math/rand"
 	"time"
 )
@@ -18,6 +19,37 @@ type Model interface {
 	Fit(trainSet TrainSet)
 }
 
+// IncrementalModel is a model which could be updated by new ratings after
+// fitted, without fitting on all ratings again.
+type IncrementalModel interface {
+	Model
+	// Update a fitted model with new ratings. New users and new items are added
+	// into the model.
+	PartialFit(dataSet DataSet)
+}
+
+// paramsModel is a model whose parameters could be retrieved. Models embedding
+// Base implement it.
+type paramsModel interface {
+	GetParams() Parameters
+}
+
+// Get parameters of a model. Nil is returned if parameters of the model couldn't
+// be retrieved.
+func paramsOf(model Model) Parameters {
+	if m, ok := model.(paramsModel); ok {
+		return m.GetParams()
+	}
+	return nil
+}
+
+// TimeModel is a model which predicts ratings at given timestamps.
+type TimeModel interface {
+	Model
+	// Predict the rating given by a user to an item at a timestamp.
+	PredictWithTime(userId, itemId int, timestamp int64) float64
+}
+
 // Parameters for an algorithm. Given by:
 //   map[string]interface{}{
 //	   "<parameter name 1>": <parameter value 1>,
@@ -71,14 +103,43 @@ func (parameters Parameters) GetString(name string, _default string) string {
 // Get a similarity function from parameters.
 func (parameters Parameters) GetSim(name string, _default Similarity) Similarity {
 	if val, exist := parameters[name]; exist {
+		if sim, ok := val.(func(SortedIdRatings, SortedIdRatings) float64); ok {
+			return sim
+		}
 		return val.(Similarity)
 	}
 	return _default
 }
 
+// Get a baseline similarity function from parameters.
+func (parameters Parameters) GetBaselineSim(name string, _default BaselineSimilarity) BaselineSimilarity {
+	if val, exist := parameters[name]; exist {
+		if sim, ok := val.(func(SortedIdRatings, SortedIdRatings, int) float64); ok {
+			return sim
+		}
+		return val.(BaselineSimilarity)
+	}
+	return _default
+}
+
+// Get an optimizer from parameters.
 func (parameters Parameters) GetOptimizer(name string, _default Optimizer) Optimizer {
 	if val, exist := parameters[name]; exist {
-		return val.(func(OptModel, TrainSet, int))
+		if optimizer, ok := val.(func(OptModel, TrainSet, int)); ok {
+			return optimizer
+		}
+		return val.(Optimizer)
+	}
+	return _default
+}
+
+// Get a splitter from parameters.
+func (parameters Parameters) GetSplitter(name string, _default Splitter) Splitter {
+	if val, exist := parameters[name]; exist {
+		if splitter, ok := val.(func(DataSet, int64) ([]TrainSet, []DataSet)); ok {
+			return splitter
+		}
+		return val.(Splitter)
 	}
 	return _default
 }
@@ -98,6 +159,10 @@ func (base *Base) SetParams(params Parameters) {
 	base.randState = base.Params.GetInt("randState", int(time.Now().UnixNano()))
 }
 
+func (base *Base) GetParams() Parameters {
+	return base.Params
+}
+
 func (base *Base) Predict(userId, itemId int) float64 {
 	panic("Predict() not implemented")
 }
@@ -215,6 +280,7 @@ type BaseLine struct {
 // 				  optimized. Default is 0.02.
 //	 lr 		- The learning rate of SGD. Default is 0.005.
 //	 nEpochs	- The number of iteration of the SGD procedure. Default is 20.
+//	 partialEpochs	- The number of iteration of the SGD procedure in PartialFit. Default is 1.
 func NewBaseLine(params Parameters) *BaseLine {
 	baseLine := new(BaseLine)
 	baseLine.Params = params
@@ -236,14 +302,30 @@ func (baseLine *BaseLine) Predict(userId, itemId int) float64 {
 }
 
 func (baseLine *BaseLine) Fit(trainSet TrainSet) {
-	// Setup parameters
-	reg := baseLine.Params.GetFloat64("reg", 0.02)
-	lr := baseLine.Params.GetFloat64("lr", 0.005)
-	nEpochs := baseLine.Params.GetInt("nEpochs", 20)
 	// Initialize parameters
 	baseLine.Data = trainSet
 	baseLine.UserBias = make([]float64, trainSet.UserCount)
 	baseLine.ItemBias = make([]float64, trainSet.ItemCount)
+	baseLine.sgd(trainSet, baseLine.Params.GetInt("nEpochs", 20), true)
+}
+
+// PartialFit updates a fitted baseline model with new ratings. Biases of new users
+// and new items are initialized as zeros and biases of users and items are updated
+// by SGD on new ratings only for partialEpochs epochs. The global bias is kept so that
+// predictions for other users don't drift.
+func (baseLine *BaseLine) PartialFit(dataSet DataSet) {
+	delta := baseLine.Data.Append(dataSet)
+	baseLine.UserBias = append(baseLine.UserBias, make([]float64, delta.UserCount-len(baseLine.UserBias))...)
+	baseLine.ItemBias = append(baseLine.ItemBias, make([]float64, delta.ItemCount-len(baseLine.ItemBias))...)
+	baseLine.sgd(delta, baseLine.Params.GetInt("partialEpochs", 1), false)
+}
+
+// Update biases by SGD on a train set for nEpochs epochs. The global bias is fixed
+// if updateGlobalBias is false.
+func (baseLine *BaseLine) sgd(trainSet TrainSet, nEpochs int, updateGlobalBias bool) {
+	// Setup parameters
+	reg := baseLine.Params.GetFloat64("reg", 0.02)
+	lr := baseLine.Params.GetFloat64("lr", 0.005)
 	// Stochastic Gradient Descent
 	for epoch := 0; epoch < nEpochs; epoch++ {
 		for i := 0; i < trainSet.Length(); i++ {
@@ -258,9 +340,102 @@ func (baseLine *BaseLine) Fit(trainSet TrainSet) {
 			gradUserBias := diff + reg*userBias
 			gradItemBias := diff + reg*itemBias
 			// Update parameters
-			baseLine.GlobalBias -= lr * gradGlobalBias
+			if updateGlobalBias {
+				baseLine.GlobalBias -= lr * gradGlobalBias
+			}
 			baseLine.UserBias[innerUserId] -= lr * gradUserBias
 			baseLine.ItemBias[innerItemId] -= lr * gradItemBias
 		}
 	}
 }
+
+/* ItemPop */
+
+// ItemPop ranks items by popularity, which is the number of interactions
+// with each item in the train set. It is a non-personalized baseline for
+// ranking. The score of a new item is zero.
+type ItemPop struct {
+	Base
+	Popularity []float64 // The popularity of each item
+}
+
+// NewItemPop creates an ItemPop model.
+func NewItemPop(params Parameters) *ItemPop {
+	pop := new(ItemPop)
+	pop.Params = params
+	return pop
+}
+
+// Predict by an ItemPop model.
+func (pop *ItemPop) Predict(userId, itemId int) float64 {
+	innerItemId := pop.Data.ConvertItemId(itemId)
+	if innerItemId == NewId {
+		return 0
+	}
+	return pop.Popularity[innerItemId]
+}
+
+// Fit an ItemPop model.
+func (pop *ItemPop) Fit(trainSet TrainSet) {
+	pop.Base.Fit(trainSet)
+	pop.Popularity = make([]float64, trainSet.ItemCount)
+	for i := 0; i < trainSet.Length(); i++ {
+		_, itemId, _ := trainSet.Index(i)
+		pop.Popularity[trainSet.ConvertItemId(itemId)]++
+	}
+}
+
+/* TrendingPop */
+
+// TrendingPop ranks items by recent popularity. Only interactions in a time
+// window before the latest interaction in the train set are counted, and each
+// interaction is weighted by an exponential recency decay:
+//
+//   w = 2^{-age/halfLife}
+//
+// where age is the number of days between the interaction and the latest
+// interaction. Interactions without timestamps are treated as the latest.
+type TrendingPop struct {
+	ItemPop
+}
+
+// NewTrendingPop creates a TrendingPop model. Parameters:
+//   window     - The number of days in the time window. Default is 30.
+//   halfLife   - The half-life (days) of the recency decay. Default is 0 (no decay).
+func NewTrendingPop(params Parameters) *TrendingPop {
+	pop := new(TrendingPop)
+	pop.Params = params
+	return pop
+}
+
+// Fit a TrendingPop model.
+func (pop *TrendingPop) Fit(trainSet TrainSet) {
+	pop.Base.Fit(trainSet)
+	// Setup parameters
+	window := pop.Params.GetFloat64("window", 30)
+	halfLife := pop.Params.GetFloat64("halfLife", 0)
+	// Find the latest interaction
+	var latest int64
+	for i := 0; i < trainSet.Length(); i++ {
+		if timestamp := trainSet.Timestamp(i); timestamp > latest {
+			latest = timestamp
+		}
+	}
+	// Count recent interactions
+	pop.Popularity = make([]float64, trainSet.ItemCount)
+	for i := 0; i < trainSet.Length(); i++ {
+		_, itemId, _ := trainSet.Index(i)
+		age := 0.0
+		if timestamp := trainSet.Timestamp(i); timestamp > 0 {
+			age = float64(latest-timestamp) / secondsPerDay
+		}
+		if age > window {
+			continue
+		}
+		weight := 1.0
+		if halfLife > 0 {
+			weight = math.Pow(2, -age/halfLife)
+		}
+		pop.Popularity[trainSet.ConvertItemId(itemId)] += weight
+	}
+}
@@ -1,8 +1,12 @@
 package core
 
 import (
+	"gonum.org/v1/gonum/floats"
 	"gonum.org/v1/gonum/stat"
+	"math"
+	"math/rand"
 	"runtime"
+	"sort"
 	"testing"
 )
 
@@ -10,22 +14,64 @@ const estimatorEpsilon float64 = 0.008
 
 func Evaluate(t *testing.T, algo Model, dataSet DataSet,
 	expectRMSE float64, expectMAE float64) {
-	// Cross validation
-	results := CrossValidate(algo, dataSet, []Evaluator{RMSE, MAE}, NewKFoldSplitter(5), 0, Parameters{
-		"randState": 0,
-	}, runtime.NumCPU())
+	EvaluateWithParams(t, algo, dataSet, Parameters{}, expectRMSE, expectMAE)
+}
+
+func EvaluateWithParams(t *testing.T, algo Model, dataSet DataSet, params Parameters,
+	expectRMSE float64, expectMAE float64) {
+	rmse, mae := evaluate(algo, dataSet, params)
 	// Check RMSE
-	rmse := stat.Mean(results[0].Tests, nil)
 	if rmse > expectRMSE+estimatorEpsilon {
 		t.Fatalf("RMSE(%.3f) > %.3f+%.3f", rmse, expectRMSE, estimatorEpsilon)
 	}
 	// Check MAE
-	mae := stat.Mean(results[1].Tests, nil)
 	if mae > expectMAE+estimatorEpsilon {
 		t.Fatalf("MAE(%.3f) > %.3f+%.3f", mae, expectMAE, estimatorEpsilon)
 	}
 }
 
+func evaluate(algo Model, dataSet DataSet, params Parameters) (float64, float64) {
+	// Cross validation
+	params["randState"] = 0
+	results := CrossValidate(algo, dataSet, []Evaluator{RMSE, MAE}, NewKFoldSplitter(5), 0,
+		params, runtime.NumCPU())
+	return stat.Mean(results[0].Tests, nil), stat.Mean(results[1].Tests, nil)
+}
+
+func EvaluateRank(t *testing.T, algo Model, dataSet DataSet, params Parameters, expectAUC float64) {
+	auc := evaluateAUC(algo, dataSet, params)
+	// Check AUC
+	if auc < expectAUC-estimatorEpsilon {
+		t.Fatalf("AUC(%.3f) < %.3f-%.3f", auc, expectAUC, estimatorEpsilon)
+	}
+}
+
+func evaluateAUC(algo Model, dataSet DataSet, params Parameters) float64 {
+	// Cross validation
+	params["randState"] = 0
+	results := CrossValidate(algo, dataSet, []Evaluator{NewAUCEvaluator(dataSet)}, NewUserLOOSplitter(1), 0,
+		params, runtime.NumCPU())
+	return stat.Mean(results[0].Tests, nil)
+}
+
+// hardNegativeAccuracy returns the fraction of training positives ranked above
+// the hardest of 20 random negatives.
+func hardNegativeAccuracy(model Model, trainSet TrainSet) float64 {
+	sampler := NewNegativeSampler(Parameters{"sampler": "hard", "nCandidates": 20}, model, trainSet, 1)
+	hit, count := 0.0, 0.0
+	for u, irs := range trainSet.UserRatings() {
+		userId := trainSet.OuterUserId(u)
+		for _, ir := range irs {
+			negId := sampler.Sample(u, ir.Id)
+			if model.Predict(userId, trainSet.OuterItemId(ir.Id)) > model.Predict(userId, trainSet.OuterItemId(negId)) {
+				hit++
+			}
+			count++
+		}
+	}
+	return hit / count
+}
+
 func TestRandom(t *testing.T) {
 	Evaluate(t, NewRandom(nil), LoadDataFromBuiltIn("ml-100k"), 1.514, 1.215)
 }
@@ -34,14 +80,94 @@ func TestBaseLine(t *testing.T) {
 	Evaluate(t, NewBaseLine(nil), LoadDataFromBuiltIn("ml-100k"), 0.944, 0.748)
 }
 
+func TestItemPop(t *testing.T) {
+	EvaluateRank(t, NewItemPop(nil), LoadDataFromBuiltIn("ml-100k"), Parameters{}, 0.740)
+}
+
+func TestTrendingPop(t *testing.T) {
+	EvaluateRank(t, NewTrendingPop(nil), LoadDataFromBuiltIn("ml-100k"), Parameters{}, 0.695)
+}
+
 func TestSVD(t *testing.T) {
 	Evaluate(t, NewSVD(nil), LoadDataFromBuiltIn("ml-100k"), 0.934, 0.737)
 }
 
+func TestSVDWithALS(t *testing.T) {
+	EvaluateWithParams(t, NewSVD(nil), LoadDataFromBuiltIn("ml-100k"), Parameters{
+		"optimizer": ALSOptimizer,
+		"reg":       0.1,
+		"nEpochs":   10,
+	}, 0.934, 0.737)
+}
+
+func TestSVDWithHogwild(t *testing.T) {
+	// Hogwild should be as accurate as sequential SGD
+	dataSet := LoadDataFromBuiltIn("ml-100k")
+	sgdRMSE, sgdMAE := evaluate(NewSVD(nil), dataSet, Parameters{})
+	rmse, mae := evaluate(NewSVD(nil), dataSet, Parameters{
+		"optimizer": HogwildSGDOptimizer,
+	})
+	if rmse > sgdRMSE+0.002 || mae > sgdMAE+0.002 {
+		t.Fatalf("RMSE(%.3f) and MAE(%.3f) are worse than SGD (%.3f, %.3f)", rmse, mae, sgdRMSE, sgdMAE)
+	}
+	// Same thresholds as SGD
+	if rmse > 0.934+estimatorEpsilon {
+		t.Fatalf("RMSE(%.3f) > %.3f+%.3f", rmse, 0.934, estimatorEpsilon)
+	}
+	if mae > 0.737+estimatorEpsilon {
+		t.Fatalf("MAE(%.3f) > %.3f+%.3f", mae, 0.737, estimatorEpsilon)
+	}
+}
+
+func TestSVDWithAdam(t *testing.T) {
+	EvaluateWithParams(t, NewSVD(nil), LoadDataFromBuiltIn("ml-100k"), Parameters{
+		"updater": "adam",
+		"lr":      0.001,
+	}, 0.920, 0.745)
+}
+
+func TestSVDWithWARP(t *testing.T) {
+	dataSet := LoadDataFromBuiltIn("ml-100k")
+	EvaluateRank(t, NewSVD(nil), dataSet, Parameters{
+		"optimizer": WARPOptimizer,
+		"pairReg":   0.02,
+	}, 0.740)
+	// WARP should push down the negatives ranked highest by the model
+	trainSet := NewTrainSet(dataSet)
+	svd := NewSVD(Parameters{"randState": 0, "optimizer": WARPOptimizer, "pairReg": 0.02})
+	svd.Fit(trainSet)
+	bpr := NewSVD(Parameters{"randState": 0, "optimizer": BPROptimizer})
+	bpr.Fit(trainSet)
+	accuracy := hardNegativeAccuracy(svd, trainSet)
+	bprAccuracy := hardNegativeAccuracy(bpr, trainSet)
+	if accuracy <= bprAccuracy {
+		t.Fatalf("Accuracy against hard negatives (%.3f) <= accuracy of BPR (%.3f)", accuracy, bprAccuracy)
+	}
+}
+
+func TestSVDWithHardSampler(t *testing.T) {
+	trainSet := NewTrainSet(LoadDataFromBuiltIn("ml-100k"))
+	svd := NewSVD(Parameters{"randState": 0, "optimizer": BPROptimizer, "sampler": "hard"})
+	svd.Fit(trainSet)
+	uniformSVD := NewSVD(Parameters{"randState": 0, "optimizer": BPROptimizer, "sampler": "uniform"})
+	uniformSVD.Fit(trainSet)
+	// Hard negatives should push down the negatives ranked highest by the model
+	accuracy := hardNegativeAccuracy(svd, trainSet)
+	uniformAccuracy := hardNegativeAccuracy(uniformSVD, trainSet)
+	if accuracy <= uniformAccuracy {
+		t.Fatalf("Accuracy against hard negatives (%.3f) <= accuracy with the uniform sampler (%.3f)",
+			accuracy, uniformAccuracy)
+	}
+}
+
 //func TestSVDPP(t *testing.T) {
 //	Evaluate(t, NewSVDpp(), LoadDataFromBuiltIn(), 0.92, 0.722)
 //}
 
+func TestTimeSVDpp(t *testing.T) {
+	Evaluate(t, NewTimeSVDpp(nil), LoadDataFromBuiltIn("ml-100k"), 0.907, 0.731)
+}
+
 func TestNMF(t *testing.T) {
 	Evaluate(t, NewNMF(nil), LoadDataFromBuiltIn("ml-100k"), 0.963, 0.758)
 }
@@ -50,6 +176,14 @@ func TestSlopeOne(t *testing.T) {
 	Evaluate(t, NewSlopOne(nil), LoadDataFromBuiltIn("ml-100k"), 0.946, 0.743)
 }
 
+func TestWeightedSlopeOne(t *testing.T) {
+	Evaluate(t, NewWeightedSlopeOne(nil), LoadDataFromBuiltIn("ml-100k"), 1.012, 0.821)
+}
+
+func TestBiPolarSlopeOne(t *testing.T) {
+	Evaluate(t, NewBiPolarSlopeOne(nil), LoadDataFromBuiltIn("ml-100k"), 0.997, 0.808)
+}
+
 func TestKNN(t *testing.T) {
 	Evaluate(t, NewKNN(nil), LoadDataFromBuiltIn("ml-100k"), 0.98, 0.774)
 }
@@ -69,3 +203,677 @@ func TestKNNBaseLine(t *testing.T) {
 func TestCoClustering(t *testing.T) {
 	Evaluate(t, NewCoClustering(nil), LoadDataFromBuiltIn("ml-100k"), 0.963, 0.753)
 }
+
+func TestFM(t *testing.T) {
+	EvaluateWithParams(t, NewFM(nil), LoadDataFromBuiltIn("ml-100k"), Parameters{
+		"lr": 0.01,
+	}, 0.940, 0.740)
+}
+
+func TestAutoRec(t *testing.T) {
+	Evaluate(t, NewAutoRec(nil), LoadDataFromBuiltIn("ml-100k"), 0.842, 0.680)
+}
+
+func TestWRMF(t *testing.T) {
+	dataSet := LoadDataFromBuiltIn("ml-100k")
+	auc := evaluateAUC(NewWRMF(nil), dataSet, Parameters{})
+	// Confidence weighting should help
+	unweightedAUC := evaluateAUC(NewWRMF(nil), dataSet, Parameters{"alpha": 0.0})
+	if auc <= unweightedAUC {
+		t.Fatalf("AUC(%.3f) <= AUC without confidence weighting(%.3f)", auc, unweightedAUC)
+	}
+}
+
+func TestEASE(t *testing.T) {
+	dataSet := LoadDataFromBuiltIn("ml-100k")
+	auc := evaluateAUC(NewEASE(nil), dataSet, Parameters{})
+	// Compare with item-based KNN
+	knnAUC := evaluateAUC(NewKNN(nil), dataSet, Parameters{"userBased": false})
+	if auc <= knnAUC {
+		t.Fatalf("AUC(%.3f) <= AUC of item-based KNN(%.3f)", auc, knnAUC)
+	}
+}
+
+func TestEASE_Weights(t *testing.T) {
+	users := []int{0, 0, 0, 1, 1, 2, 2, 2, 3, 3, 4, 4, 4}
+	items := []int{0, 1, 2, 1, 3, 0, 2, 3, 2, 4, 0, 1, 4}
+	trainSet := NewTrainSet(NewRawDataSet(users, items, make([]float64, len(users))))
+	const reg = 1.0
+	ease := NewEASE(Parameters{"reg": reg})
+	ease.Fit(trainSet)
+	// G = X^TX + λI
+	xtx := newZeroMatrix(trainSet.ItemCount, trainSet.ItemCount)
+	for _, irs := range trainSet.UserRatings() {
+		for _, ir := range irs {
+			for _, jr := range irs {
+				xtx[ir.Id][jr.Id]++
+			}
+		}
+	}
+	// B minimizes the cost s.t. diag(B) = 0 iff GB - X^TX is diagonal
+	for j, weights := range ease.Weights {
+		column := make([]float64, trainSet.ItemCount)
+		for k, i := range weights.Indices {
+			column[i] = weights.Values[k]
+		}
+		if column[j] != 0 {
+			t.Fatalf("B(%d, %d) = %f != 0", j, j, column[j])
+		}
+		for i := 0; i < trainSet.ItemCount; i++ {
+			if i == j {
+				continue
+			}
+			gb := reg * column[i]
+			for k := 0; k < trainSet.ItemCount; k++ {
+				gb += xtx[i][k] * column[k]
+			}
+			if math.Abs(gb-xtx[i][j]) > 1e-9 {
+				t.Fatalf("(GB)(%d, %d) = %f != (X^TX)(%d, %d) = %f", i, j, gb, i, j, xtx[i][j])
+			}
+		}
+	}
+}
+
+func TestSLIM(t *testing.T) {
+	dataSet := LoadDataFromBuiltIn("ml-100k")
+	auc := evaluateAUC(NewSLIM(nil), dataSet, Parameters{})
+	// Compare with item-based KNN
+	knnAUC := evaluateAUC(NewKNN(nil), dataSet, Parameters{"userBased": false})
+	if auc <= knnAUC {
+		t.Fatalf("AUC(%.3f) <= AUC of item-based KNN(%.3f)", auc, knnAUC)
+	}
+}
+
+func TestSLIM_Weights(t *testing.T) {
+	trainSet := NewTrainSet(LoadDataFromBuiltIn("ml-100k"))
+	countWeights := func(slim *SLIM) int {
+		count := 0
+		for j, weights := range slim.Weights {
+			for k, i := range weights.Indices {
+				if i == j || weights.Values[k] <= 0 {
+					t.Fatalf("W(%d, %d) = %f isn't a positive weight off the diagonal", i, j, weights.Values[k])
+				}
+			}
+			count += weights.Len()
+		}
+		return count
+	}
+	slim := NewSLIM(nil)
+	slim.Fit(trainSet)
+	count := countWeights(slim)
+	// L1 regularization should make weights sparse
+	denseSLIM := NewSLIM(Parameters{"l1Reg": 0.0})
+	denseSLIM.Fit(trainSet)
+	if denseCount := countWeights(denseSLIM); count >= denseCount {
+		t.Fatalf("Number of weights (%d) >= number of weights without L1 regularization (%d)", count, denseCount)
+	}
+}
+
+func TestKNNBaseLineWithPearsonBaseline(t *testing.T) {
+	EvaluateWithParams(t, NewKNNBaseLine(nil), LoadDataFromBuiltIn("ml-100k"), Parameters{
+		"sim": PearsonBaseline,
+	}, 0.919, 0.724)
+}
+
+func TestEnsemble(t *testing.T) {
+	dataSet := LoadDataFromBuiltIn("ml-100k")
+	rmse, mae := evaluate(NewEnsemble(nil, NewSVD(nil), NewKNNBaseLine(nil), NewSlopOne(nil)), dataSet, Parameters{})
+	// Blending should be better than every base model
+	for _, model := range []Model{NewSVD(nil), NewKNNBaseLine(nil), NewSlopOne(nil)} {
+		modelRMSE, modelMAE := evaluate(model, dataSet, Parameters{})
+		if rmse >= modelRMSE || mae >= modelMAE {
+			t.Fatalf("RMSE(%.3f) and MAE(%.3f) of %T aren't worse than the ensemble: RMSE(%.3f) and MAE(%.3f)",
+				modelRMSE, modelMAE, model, rmse, mae)
+		}
+	}
+}
+
+func TestEnsemble_Copy(t *testing.T) {
+	// Functions in parameters can't be encoded by gob
+	dataSet := LoadDataFromBuiltIn("ml-100k")
+	params := Parameters{"splitter": NewKFoldSplitter(3)}
+	svd := NewSVD(Parameters{"optimizer": SGDOptimizer})
+	ensemble := NewEnsemble(params, NewBaseLine(nil), svd)
+	rmse, _ := evaluate(ensemble, dataSet, params.Copy())
+	baseRMSE, _ := evaluate(NewBaseLine(nil), dataSet, Parameters{})
+	if rmse >= baseRMSE {
+		t.Fatalf("RMSE(%.3f) >= RMSE of BaseLine(%.3f)", rmse, baseRMSE)
+	}
+	// Base models passed by the caller aren't fitted
+	ensemble.Fit(NewTrainSet(dataSet))
+	if svd.UserFactor != nil || ensemble.Models[1] == Model(svd) {
+		t.Fatalf("the base model passed to NewEnsemble is fitted")
+	}
+}
+
+func TestEnsemble_Singular(t *testing.T) {
+	// Predictions of identical base models are collinear
+	trainSet := NewTrainSet(LoadDataFromBuiltIn("ml-100k"))
+	ensemble := NewEnsemble(Parameters{"reg": 0.0, "randState": 0},
+		NewBaseLine(Parameters{"randState": 0}), NewBaseLine(Parameters{"randState": 0}))
+	ensemble.Fit(trainSet)
+	// Fall back to the average
+	if !floats.Equal(ensemble.Weights, []float64{0.5, 0.5}) || ensemble.Intercept != 0 {
+		t.Fatalf("expect weights [0.5 0.5] and intercept 0, get %v and %v", ensemble.Weights, ensemble.Intercept)
+	}
+}
+
+func TestContentBased(t *testing.T) {
+	// Feature 0 is shared by all items. Items 2, 3 and 4 aren't rated.
+	itemFeatures := map[int]SparseVector{
+		0: NewSparseVector([]int{0, 1}, []float64{1, 1}),
+		1: NewSparseVector([]int{0, 2}, []float64{1, 1}),
+		2: NewSparseVector([]int{0, 1}, []float64{1, 1}),
+		3: NewSparseVector([]int{0, 2}, []float64{1, 1}),
+		4: NewSparseVector([]int{0}, []float64{1}),
+	}
+	trainSet := NewTrainSet(NewRawDataSet([]int{0, 0}, []int{0, 1}, []float64{5, 1}))
+	cb := NewContentBased(nil)
+	cb.ItemFeatures = itemFeatures
+	cb.Fit(trainSet)
+	// The user profile is weighted by ratings
+	if cb.Predict(0, 2) <= cb.Predict(0, 3) {
+		t.Fatalf("Predict(0, 2) = %f <= Predict(0, 3) = %f", cb.Predict(0, 2), cb.Predict(0, 3))
+	}
+	// TF-IDF ignores the feature shared by all items
+	if score := cb.Predict(0, 4); score != 0 {
+		t.Fatalf("Predict(0, 4) = %f != 0", score)
+	}
+	binaryCB := NewContentBased(Parameters{"weighting": "binary"})
+	binaryCB.ItemFeatures = itemFeatures
+	binaryCB.Fit(trainSet)
+	if score := binaryCB.Predict(0, 4); score <= 0 {
+		t.Fatalf("Predict(0, 4) = %f <= 0 with binary weighting", score)
+	}
+}
+
+func TestContentBased_NewItem(t *testing.T) {
+	cb := NewContentBased(Parameters{"weighting": "binary"})
+	cb.ItemFeatures = map[int]SparseVector{
+		0: NewSparseVector([]int{0}, []float64{1}),
+		1: NewSparseVector([]int{1}, []float64{1}),
+		2: NewSparseVector([]int{0}, []float64{1}),
+	}
+	cb.Fit(NewTrainSet(NewRawDataSet([]int{0, 1}, []int{0, 1}, []float64{5, 5})))
+	// Item 2 isn't in the train set but shares features with item 0
+	if score := cb.Predict(0, 2); score != 1 {
+		t.Fatalf("Predict(0, 2) = %f != 1", score)
+	}
+	if score := cb.Predict(1, 2); score != 0 {
+		t.Fatalf("Predict(1, 2) = %f != 0", score)
+	}
+}
+
+func TestLightFM(t *testing.T) {
+	// Users with even (odd) IDs rate 4 of 100 items with even (odd) IDs. The
+	// feature of an item is the parity of its ID.
+	rng := rand.New(rand.NewSource(0))
+	users, items, ratings := []int{}, []int{}, []float64{}
+	itemFeatures := make(map[int]SparseVector)
+	for i := 0; i < 200; i++ {
+		itemFeatures[i] = NewSparseVector([]int{i % 2}, []float64{1})
+	}
+	for u := 0; u < 200; u++ {
+		rated := make(map[int]bool)
+		for len(rated) < 4 {
+			if i := 2*rng.Intn(100) + u%2; !rated[i] {
+				rated[i] = true
+				users = append(users, u)
+				items = append(items, i)
+				ratings = append(ratings, 1)
+			}
+		}
+	}
+	dataSet := NewRawDataSet(users, items, ratings)
+	// Item features should help on sparse ratings
+	for _, optimizer := range []Optimizer{LogisticOptimizer, BPROptimizer, WARPOptimizer} {
+		lfm := NewLightFM(nil)
+		lfm.ItemFeatures = itemFeatures
+		auc := evaluateAUC(lfm, dataSet, Parameters{"optimizer": optimizer})
+		idAUC := evaluateAUC(NewLightFM(nil), dataSet, Parameters{"optimizer": optimizer})
+		if auc <= idAUC {
+			t.Fatalf("AUC(%.3f) <= AUC without item features(%.3f)", auc, idAUC)
+		}
+	}
+}
+
+func TestLightFM_ColdStart(t *testing.T) {
+	// Users with feature 0 rate items with feature 0 and users with
+	// feature 1 rate items with feature 1.
+	users, items, ratings := []int{}, []int{}, []float64{}
+	userFeatures := make(map[int]SparseVector)
+	itemFeatures := make(map[int]SparseVector)
+	for u := 0; u < 22; u++ {
+		userFeatures[u] = NewSparseVector([]int{u % 2}, []float64{1})
+	}
+	for i := 0; i < 22; i++ {
+		itemFeatures[i] = NewSparseVector([]int{i % 2}, []float64{1})
+	}
+	for u := 0; u < 20; u++ {
+		for i := u % 2; i < 20; i += 2 {
+			users = append(users, u)
+			items = append(items, i)
+			ratings = append(ratings, 1)
+		}
+	}
+	lfm := NewLightFM(Parameters{"randState": 0, "nEpochs": 50})
+	lfm.UserFeatures = userFeatures
+	lfm.ItemFeatures = itemFeatures
+	lfm.Fit(NewTrainSet(NewRawDataSet(users, items, ratings)))
+	// Cold users 20, 21 and cold items 20, 21
+	for u := 20; u < 22; u++ {
+		for _, i := range []int{0, 1, 20, 21} {
+			if i%2 == u%2 && lfm.Predict(u, i) <= lfm.Predict(u, i+1-2*(i%2)) {
+				t.Fatalf("cold user %d prefers item %d to item %d", u, i+1-2*(i%2), i)
+			}
+		}
+	}
+}
+
+func TestFPMC(t *testing.T) {
+	// Each user visits 10 successive items of 50 items in a ring from a random
+	// item. The last item is held out.
+	rng := rand.New(rand.NewSource(0))
+	const nItems, nSteps = 50, 10
+	users, items, timestamps, shuffledTimestamps := []int{}, []int{}, []int64{}, []int64{}
+	testUsers, testItems := []int{}, []int{}
+	for u := 0; u < 200; u++ {
+		start := rng.Intn(nItems)
+		perm := rng.Perm(nSteps - 1)
+		for t := 0; t < nSteps-1; t++ {
+			users = append(users, u)
+			items = append(items, (start+t)%nItems)
+			timestamps = append(timestamps, int64(t))
+			shuffledTimestamps = append(shuffledTimestamps, int64(perm[t]))
+		}
+		testUsers = append(testUsers, u)
+		testItems = append(testItems, (start+nSteps-1)%nItems)
+	}
+	ratings := make([]float64, len(users))
+	testSet := NewRawDataSet(testUsers, testItems, make([]float64, len(testUsers)))
+	fullSet := NewRawDataSet(append(testUsers, users...), append(testItems, items...),
+		make([]float64, len(testUsers)+len(users)))
+	auc := NewAUCEvaluator(fullSet)
+	fpmc := NewFPMC(Parameters{"randState": 0})
+	fpmc.Fit(NewTrainSet(NewRawDataSetWithTimestamp(users, items, ratings, timestamps)))
+	// Interaction order should help
+	shuffledFPMC := NewFPMC(Parameters{"randState": 0})
+	shuffledFPMC.Fit(NewTrainSet(NewRawDataSetWithTimestamp(users, items, ratings, shuffledTimestamps)))
+	if fpmcAUC, shuffledAUC := auc(fpmc, testSet), auc(shuffledFPMC, testSet); fpmcAUC <= shuffledAUC {
+		t.Fatalf("AUC(%.3f) <= AUC with shuffled timestamps(%.3f)", fpmcAUC, shuffledAUC)
+	}
+}
+
+func TestFPMC_Recommend(t *testing.T) {
+	// Users visit items 0, 1, ..., 19, 0, 1, ... in turn from different items
+	users, items, ratings, timestamps := []int{}, []int{}, []float64{}, []int64{}
+	for u := 0; u < 100; u++ {
+		for t := 0; t < 8; t++ {
+			users = append(users, u)
+			items = append(items, (u+t)%20)
+			ratings = append(ratings, 1)
+			timestamps = append(timestamps, int64(t))
+		}
+	}
+	fpmc := NewFPMC(Parameters{"randState": 0, "nEpochs": 50})
+	fpmc.Fit(NewTrainSet(NewRawDataSetWithTimestamp(users, items, ratings, timestamps)))
+	// The next item of a new user is predicted by transitions
+	for i := 0; i < 20; i++ {
+		if next, _ := fpmc.Recommend(100, []int{i}, 1); next[0] != (i+1)%20 {
+			t.Fatalf("the next item after %d is %d != %d", i, next[0], (i+1)%20)
+		}
+	}
+}
+
+func TestItem2Vec(t *testing.T) {
+	dataSet := LoadDataFromBuiltIn("ml-100k")
+	auc := evaluateAUC(NewItem2Vec(nil), dataSet, Parameters{})
+	// Compare with item-based KNN
+	knnAUC := evaluateAUC(NewKNN(nil), dataSet, Parameters{"userBased": false})
+	if auc <= knnAUC {
+		t.Fatalf("AUC(%.3f) <= AUC of item-based KNN(%.3f)", auc, knnAUC)
+	}
+}
+
+func TestItem2Vec_Similarity(t *testing.T) {
+	// Users with even IDs rate items 0~9 and users with odd IDs rate items 10~19
+	users, items, ratings := []int{}, []int{}, []float64{}
+	for u := 0; u < 100; u++ {
+		for i := 0; i < 10; i++ {
+			users = append(users, u)
+			items = append(items, u%2*10+i)
+			ratings = append(ratings, 1)
+		}
+	}
+	i2v := NewItem2Vec(Parameters{"randState": 0, "nFactors": 10})
+	i2v.Fit(NewTrainSet(NewRawDataSet(users, items, ratings)))
+	for i := 0; i < 20; i++ {
+		same, other := (i+1)%10+i/10*10, (i+10)%20
+		if i2v.Similarity(i, same) <= i2v.Similarity(i, other) {
+			t.Fatalf("Similarity(%d, %d) <= Similarity(%d, %d)", i, same, i, other)
+		}
+	}
+}
+
+func TestP3Alpha(t *testing.T) {
+	dataSet := LoadDataFromBuiltIn("ml-100k")
+	auc := evaluateAUC(NewP3Alpha(nil), dataSet, Parameters{})
+	if auc < 0.740-estimatorEpsilon {
+		t.Fatalf("AUC(%.3f) < %.3f-%.3f", auc, 0.740, estimatorEpsilon)
+	}
+	// Compare with item-based KNN
+	knnAUC := evaluateAUC(NewKNN(nil), dataSet, Parameters{"userBased": false})
+	if auc <= knnAUC {
+		t.Fatalf("AUC(%.3f) <= AUC of item-based KNN(%.3f)", auc, knnAUC)
+	}
+}
+
+func TestRP3Beta(t *testing.T) {
+	dataSet := LoadDataFromBuiltIn("ml-100k")
+	auc := evaluateAUC(NewRP3Beta(nil), dataSet, Parameters{})
+	if auc < 0.740-estimatorEpsilon {
+		t.Fatalf("AUC(%.3f) < %.3f-%.3f", auc, 0.740, estimatorEpsilon)
+	}
+	// Penalizing popular items should help
+	p3AUC := evaluateAUC(NewP3Alpha(nil), dataSet, Parameters{})
+	if auc < p3AUC {
+		t.Fatalf("AUC(%.3f) < AUC of P3alpha(%.3f)", auc, p3AUC)
+	}
+}
+
+func TestRP3Beta_Recommend(t *testing.T) {
+	trainSet := NewTrainSet(LoadDataFromBuiltIn("ml-100k"))
+	rp3 := NewRP3Beta(Parameters{"k": 20})
+	rp3.Fit(trainSet)
+	userId := trainSet.OuterUserId(0)
+	rated := make(map[int]bool)
+	for _, ir := range trainSet.UserRatings()[0] {
+		rated[trainSet.OuterItemId(ir.Id)] = true
+	}
+	items, scores := rp3.Recommend(userId, 10)
+	if len(items) != 10 {
+		t.Fatalf("Number of recommended items (%d) != 10", len(items))
+	}
+	for k, itemId := range items {
+		if rated[itemId] {
+			t.Fatalf("Rated item %d is recommended", itemId)
+		}
+		if score := rp3.Predict(userId, itemId); math.Abs(score-scores[k]) > 1e-9 {
+			t.Fatalf("Score of item %d (%f) != %f", itemId, scores[k], score)
+		}
+		if k > 0 && scores[k] > scores[k-1] {
+			t.Fatalf("Scores aren't in descending order")
+		}
+	}
+}
+
+func TestSVD_FoldIn(t *testing.T) {
+	// Hold out all ratings of the first 50 users
+	dataSet := LoadDataFromBuiltIn("ml-100k")
+	fullSet := NewTrainSet(dataSet)
+	trainIndex, testIndex := []int{}, []int{}
+	for i := 0; i < dataSet.Length(); i++ {
+		userId, _, _ := dataSet.Index(i)
+		if fullSet.ConvertUserId(userId) < 50 {
+			testIndex = append(testIndex, i)
+		} else {
+			trainIndex = append(trainIndex, i)
+		}
+	}
+	svd := NewSVD(Parameters{"randState": 0})
+	svd.Fit(NewTrainSet(dataSet.SubSet(trainIndex)))
+	// Fold in the first half of ratings of each user and test on the rest
+	testSet := NewTrainSet(dataSet.SubSet(testIndex))
+	before, after, count := 0.0, 0.0, 0.0
+	for u, irs := range testSet.UserRatings() {
+		userId := testSet.OuterUserId(u)
+		itemIds, ratings := []int{}, []float64{}
+		for _, ir := range irs[:len(irs)/2] {
+			itemIds = append(itemIds, testSet.OuterItemId(ir.Id))
+			ratings = append(ratings, ir.Rating)
+		}
+		for _, ir := range irs[len(irs)/2:] {
+			diff := ir.Rating - svd.Predict(userId, testSet.OuterItemId(ir.Id))
+			before += diff * diff
+		}
+		svd.FoldIn(userId, itemIds, ratings)
+		for _, ir := range irs[len(irs)/2:] {
+			diff := ir.Rating - svd.Predict(userId, testSet.OuterItemId(ir.Id))
+			after += diff * diff
+			count++
+		}
+	}
+	if svd.Data.UserCount != fullSet.UserCount {
+		t.Fatalf("Number of users (%d) != %d", svd.Data.UserCount, fullSet.UserCount)
+	}
+	before, after = math.Sqrt(before/count), math.Sqrt(after/count)
+	if after >= before {
+		t.Fatalf("RMSE after fold-in (%f) >= RMSE before fold-in (%f)", after, before)
+	}
+}
+
+// Fit a model without ratings of the first 50 users, update it with the first
+// half of ratings of these users and test on the rest. Predictions for other
+// users are tested on one tenth of their ratings, which are never fitted.
+func testPartialFit(t *testing.T, model IncrementalModel) {
+	dataSet := LoadDataFromBuiltIn("ml-100k")
+	fullSet := NewTrainSet(dataSet)
+	heldOut := make([][]int, 50)
+	trainIndex, updateIndex, existingIndex := []int{}, []int{}, []int{}
+	for i := 0; i < dataSet.Length(); i++ {
+		userId, _, _ := dataSet.Index(i)
+		if innerUserId := fullSet.ConvertUserId(userId); innerUserId < 50 {
+			heldOut[innerUserId] = append(heldOut[innerUserId], i)
+		} else if i%10 == 0 {
+			existingIndex = append(existingIndex, i)
+		} else {
+			trainIndex = append(trainIndex, i)
+		}
+	}
+	testIndex := []int{}
+	for _, indices := range heldOut {
+		updateIndex = append(updateIndex, indices[:len(indices)/2]...)
+		testIndex = append(testIndex, indices[len(indices)/2:]...)
+	}
+	testSet, existingSet := dataSet.SubSet(testIndex), dataSet.SubSet(existingIndex)
+	model.Fit(NewTrainSet(dataSet.SubSet(trainIndex)))
+	before, existingBefore := RMSE(model, testSet), RMSE(model, existingSet)
+	model.PartialFit(dataSet.SubSet(updateIndex))
+	after, existingAfter := RMSE(model, testSet), RMSE(model, existingSet)
+	if after >= before {
+		t.Fatalf("RMSE after partial fit (%f) >= RMSE before partial fit (%f)", after, before)
+	}
+	if existingAfter > existingBefore {
+		t.Fatalf("RMSE of existing users after partial fit (%f) > RMSE before partial fit (%f)",
+			existingAfter, existingBefore)
+	}
+}
+
+func TestSVD_PartialFit(t *testing.T) {
+	testPartialFit(t, NewSVD(Parameters{"randState": 0}))
+}
+
+func TestSVD_PartialFitNegatives(t *testing.T) {
+	negatives, seeds := []int{}, []int{}
+	recorder := func(model OptModel, trainSet TrainSet, nEpochs int) {
+		if trainSet.Length() == 1 {
+			sampler := NewNegativeSampler(Parameters{}, model, trainSet, 0)
+			negatives = append(negatives, trainSet.OuterItemId(sampler.Sample(0, 0)))
+			seeds = append(seeds, paramsOf(model).GetInt("randState", 0))
+		}
+	}
+	svd := NewSVD(Parameters{"randState": 0, "optimizer": Optimizer(recorder)})
+	svd.Fit(NewTrainSet(NewRawDataSet([]int{1, 1, 2}, []int{1, 2, 4}, []float64{1, 1, 1})))
+	svd.PartialFit(NewRawDataSet([]int{1}, []int{3}, []float64{1}))
+	svd.PartialFit(NewRawDataSet([]int{1}, []int{5}, []float64{1}))
+	// Negatives are sampled against all ratings
+	if negatives[0] != 4 || negatives[1] != 4 {
+		t.Fatalf("Negatives (%v) are rated by the user", negatives)
+	}
+	// The random generator is seeded differently in each update
+	if seeds[0] == seeds[1] || svd.Params.GetInt("randState", -1) != 0 {
+		t.Fatalf("Random seeds (%v) of updates are wrong", seeds)
+	}
+}
+
+func TestSVDWithALS_PartialFit(t *testing.T) {
+	testPartialFit(t, NewSVD(Parameters{
+		"randState": 0,
+		"optimizer": ALSOptimizer,
+		"reg":       0.1,
+		"nEpochs":   10,
+	}))
+}
+
+func TestSVDpp_PartialFit(t *testing.T) {
+	testPartialFit(t, NewSVDpp(Parameters{"randState": 0, "nEpochs": 5}))
+}
+
+func TestBaseLine_PartialFit(t *testing.T) {
+	testPartialFit(t, NewBaseLine(Parameters{}))
+}
+
+func TestTimeSVDpp_PartialFit(t *testing.T) {
+	// Time-dependent parameters can't be updated incrementally
+	if _, ok := interface{}(NewTimeSVDpp(nil)).(IncrementalModel); ok {
+		t.Fatalf("TimeSVDpp shouldn't be an IncrementalModel")
+	}
+}
+
+func TestKNN_PartialFit(t *testing.T) {
+	testPartialFit(t, NewKNNWithMean(Parameters{}))
+}
+
+func TestKNN_PartialFitConsistency(t *testing.T) {
+	dataSet := NewRawDataSet(
+		[]int{1, 1, 1, 2, 2, 3, 3, 3, 4, 4, 4, 4},
+		[]int{1, 2, 3, 1, 3, 2, 3, 4, 1, 2, 3, 4},
+		[]float64{5, 3, 4, 4, 5, 2, 1, 3, 5, 4, 2, 1})
+	trainIndex, updateIndex := []int{}, []int{}
+	for i := 0; i < dataSet.Length(); i++ {
+		if i == dataSet.Length()-1 {
+			updateIndex = append(updateIndex, i)
+		} else {
+			trainIndex = append(trainIndex, i)
+		}
+	}
+	allIndex := append(append([]int{}, trainIndex...), updateIndex...)
+	// Partial fit should be consistent with fit on all ratings
+	for _, newModel := range []func() *KNN{
+		func() *KNN { return NewKNNWithMean(Parameters{"sim": Pearson}) },
+		func() *KNN { return NewKNNWithZScore(Parameters{"sim": Pearson}) },
+		func() *KNN { return NewKNNBaseLine(Parameters{"randState": 0, "sim": PearsonBaseline}) },
+	} {
+		partial, full := newModel(), newModel()
+		partial.Fit(NewTrainSet(dataSet.SubSet(trainIndex)))
+		partial.PartialFit(dataSet.SubSet(updateIndex))
+		full.Fit(NewTrainSet(dataSet.SubSet(allIndex)))
+		for userId := 1; userId <= 4; userId++ {
+			for itemId := 1; itemId <= 4; itemId++ {
+				if a, b := partial.Predict(userId, itemId), full.Predict(userId, itemId); math.Abs(a-b) > 1e-9 {
+					t.Fatalf("Prediction of partial fit (%f) != prediction of fit on all ratings (%f)", a, b)
+				}
+			}
+		}
+	}
+}
+
+func TestBPMF(t *testing.T) {
+	Evaluate(t, NewBPMF(nil), LoadDataFromBuiltIn("ml-100k"), 0.788, 0.634)
+}
+
+func TestBPMF_Thin(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expect a panic with thin < 1")
+		}
+	}()
+	NewBPMF(Parameters{"thin": 0})
+}
+
+func TestBPMF_PredictWithVariance(t *testing.T) {
+	trainSet := NewTrainSet(LoadDataFromBuiltIn("ml-100k"))
+	bpmf := NewBPMF(Parameters{"randState": 0})
+	bpmf.Fit(trainSet)
+	// Sort items by popularity
+	itemRatings := trainSet.ItemRatings()
+	items := make([]int, trainSet.ItemCount)
+	for i := range items {
+		items[i] = i
+	}
+	sort.Slice(items, func(a, b int) bool {
+		return len(itemRatings[items[a]]) < len(itemRatings[items[b]])
+	})
+	// Predictions of unpopular items are more uncertain than popular items
+	meanVariance := func(items []int) float64 {
+		sum := 0.0
+		for _, i := range items {
+			for u := 0; u < 50; u++ {
+				_, variance := bpmf.PredictWithVariance(trainSet.OuterUserId(u), trainSet.OuterItemId(i))
+				sum += variance
+			}
+		}
+		return sum / float64(len(items)*50)
+	}
+	unpopular, popular := meanVariance(items[:100]), meanVariance(items[len(items)-100:])
+	if unpopular <= popular {
+		t.Fatalf("Variance of unpopular items (%f) <= variance of popular items (%f)", unpopular, popular)
+	}
+	// New users
+	if mean, variance := bpmf.PredictWithVariance(-1, trainSet.OuterItemId(0)); mean != trainSet.GlobalMean ||
+		math.Abs(variance-trainSet.StdDev()*trainSet.StdDev()) > 1e-9 {
+		t.Fatalf("Prediction of new users (%f, %f) isn't the global mean and variance", mean, variance)
+	}
+}
+
+// Ratings of items with feature 0 are 5 and ratings of items with feature 1 are 1.
+func newFeatureDataSet() (DataSet, map[int]SparseVector) {
+	users, items, ratings := []int{}, []int{}, []float64{}
+	itemFeatures := make(map[int]SparseVector)
+	for i := 0; i < 20; i++ {
+		itemFeatures[i] = NewSparseVector([]int{i % 2}, []float64{1})
+		for u := 0; u < 10; u++ {
+			users = append(users, u)
+			items = append(items, i)
+			ratings = append(ratings, float64(5-4*(i%2)))
+		}
+	}
+	// New items
+	itemFeatures[100] = NewSparseVector([]int{0}, []float64{1})
+	itemFeatures[101] = NewSparseVector([]int{1}, []float64{1})
+	return NewRawDataSet(users, items, ratings), itemFeatures
+}
+
+func TestFM_SideFeatures(t *testing.T) {
+	dataSet, itemFeatures := newFeatureDataSet()
+	fm := NewFM(Parameters{"randState": 0, "nFactors": 10, "lr": 0.01})
+	fm.ItemFeatures = itemFeatures
+	fm.Fit(NewTrainSet(dataSet))
+	// New items are predicted by side features, while the global mean is 3
+	if score := fm.Predict(0, 100); score <= 4 {
+		t.Fatalf("Predict(0, 100) = %f, expect > 4", score)
+	}
+	if score := fm.Predict(0, 101); score >= 2 {
+		t.Fatalf("Predict(0, 101) = %f, expect < 2", score)
+	}
+}
+
+func TestFM_Classification(t *testing.T) {
+	dataSet, itemFeatures := newFeatureDataSet()
+	fm := NewFM(Parameters{"randState": 0, "nFactors": 10, "lr": 0.01, "task": "classification", "threshold": 3.0})
+	fm.ItemFeatures = itemFeatures
+	fm.Fit(NewTrainSet(dataSet))
+	// Probabilities of positive
+	if prob := fm.Predict(0, 0); prob <= 0.9 || prob >= 1 {
+		t.Fatalf("Predict(0, 0) = %f, expect (0.9, 1)", prob)
+	}
+	if prob := fm.Predict(0, 1); prob >= 0.1 || prob <= 0 {
+		t.Fatalf("Predict(0, 1) = %f, expect (0, 0.1)", prob)
+	}
+	if prob := fm.Predict(0, 100); prob <= 0.5 {
+		t.Fatalf("Predict(0, 100) = %f, expect > 0.5", prob)
+	}
+	if prob := fm.Predict(0, 101); prob >= 0.5 {
+		t.Fatalf("Predict(0, 101) = %f, expect < 0.5", prob)
+	}
+}
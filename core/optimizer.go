@@ -1,8 +1,13 @@
 package core
 
 import (
+	"gonum.org/v1/gonum/mat"
 	"math"
 	"math/rand"
+	"reflect"
+	"runtime"
+	"sync"
+	"time"
 )
 
 // OptModel supports multiple optimizers.
@@ -14,12 +19,27 @@ type OptModel interface {
 	PairUpdate(upGrad float64, innerUserId, positiveItemId, negativeItemId int)
 }
 
+// EpochModel is notified at the start of each epoch by optimizers, which
+// is used to update learning rate schedules.
+type EpochModel interface {
+	// StartEpoch is called at the start of an epoch.
+	StartEpoch(epoch int)
+}
+
 // Optimizer optimizes OptModel.
 type Optimizer func(OptModel, TrainSet, int)
 
+// Notify a model at the start of an epoch if it is an EpochModel.
+func startEpoch(model OptModel, epoch int) {
+	if epochModel, ok := model.(EpochModel); ok {
+		epochModel.StartEpoch(epoch)
+	}
+}
+
 // SGDOptimizer optimizes a factor by SGD on square error.
 func SGDOptimizer(model OptModel, trainSet TrainSet, nEpochs int) {
 	for epoch := 0; epoch < nEpochs; epoch++ {
+		startEpoch(model, epoch)
 		for i := 0; i < trainSet.Length(); i++ {
 			userId, itemId, rating := trainSet.Index(i)
 			innerUserId := trainSet.ConvertUserId(userId)
@@ -32,32 +52,25 @@ func SGDOptimizer(model OptModel, trainSet TrainSet, nEpochs int) {
 	}
 }
 
-// BPROptimizer optimizes a factor model by LearnBPR algorithm.
+// BPROptimizer optimizes a factor model by LearnBPR algorithm. Negatives are sampled by
+// the NegativeSampler created from parameters of the model by NewNegativeSampler. The
+// random generator is seeded by the parameter "randState".
 func BPROptimizer(model OptModel, trainSet TrainSet, nEpochs int) {
-	positiveSet := make([]map[int]bool, trainSet.UserCount)
-	pos := 0
-	for u, b := range trainSet.UserRatings() {
-		positiveSet[u] = make(map[int]bool)
-		for _, d := range b {
-			positiveSet[u][d.Id] = true
-			pos++
-		}
-	}
+	params := paramsOf(model)
+	rng := rand.New(rand.NewSource(int64(params.GetInt("randState", int(time.Now().UnixNano())))))
+	sampler := NewNegativeSampler(params, model, trainSet, rng.Int63())
 	for epoch := 0; epoch < nEpochs; epoch++ {
+		startEpoch(model, epoch)
 		for i := 0; i < trainSet.Length(); i++ {
 			// Select a positive
-			index := rand.Intn(trainSet.Length())
+			index := rng.Intn(trainSet.Length())
 			userId, posId, _ := trainSet.Index(index)
 			innerUserId := trainSet.ConvertUserId(userId)
 			innerPosId := trainSet.ConvertItemId(posId)
 			// Select a negative
-			negId := -1
-			for {
-				temp := rand.Intn(trainSet.ItemCount)
-				if _, exist := positiveSet[innerUserId][temp]; !exist {
-					negId = temp
-					break
-				}
+			negId := sampler.Sample(innerUserId, innerPosId)
+			if negId < 0 {
+				continue
 			}
 			outerNegId := trainSet.outerItemIds[negId]
 			diff := model.Predict(userId, posId) - model.Predict(userId, outerNegId)
@@ -67,3 +80,348 @@ func BPROptimizer(model OptModel, trainSet TrainSet, nEpochs int) {
 		}
 	}
 }
+
+// LogisticOptimizer optimizes a factor model by SGD on logistic loss. Rated items are
+// positives (y = 1) and negatives (y = 0) are sampled by the NegativeSampler created
+// from parameters of the model by NewNegativeSampler. The gradient passed to PointUpdate
+// is y - σ(\hat{x}_{ui}). The random generator is seeded by the parameter "randState".
+func LogisticOptimizer(model OptModel, trainSet TrainSet, nEpochs int) {
+	params := paramsOf(model)
+	rng := rand.New(rand.NewSource(int64(params.GetInt("randState", int(time.Now().UnixNano())))))
+	sampler := NewNegativeSampler(params, model, trainSet, rng.Int63())
+	for epoch := 0; epoch < nEpochs; epoch++ {
+		startEpoch(model, epoch)
+		for i := 0; i < trainSet.Length(); i++ {
+			// Select a positive
+			index := rng.Intn(trainSet.Length())
+			userId, posId, _ := trainSet.Index(index)
+			innerUserId := trainSet.ConvertUserId(userId)
+			innerPosId := trainSet.ConvertItemId(posId)
+			model.PointUpdate(1-sigmoid(model.Predict(userId, posId)), innerUserId, innerPosId)
+			// Select a negative
+			negId := sampler.Sample(innerUserId, innerPosId)
+			if negId < 0 {
+				continue
+			}
+			outerNegId := trainSet.outerItemIds[negId]
+			model.PointUpdate(-sigmoid(model.Predict(userId, outerNegId)), innerUserId, negId)
+		}
+	}
+}
+
+// WARPOptimizer optimizes a factor model by the WARP loss[14], where the number of
+// sampled negatives for each positive isn't limited.
+func WARPOptimizer(model OptModel, trainSet TrainSet, nEpochs int) {
+	NewWARPOptimizer(trainSet.ItemCount)(model, trainSet, nEpochs)
+}
+
+// NewWARPOptimizer creates an optimizer for the Weighted Approximate-Rank Pairwise
+// loss[14]. For each positive item i of user u, negatives are sampled until a negative
+// j violates the margin: \hat{x}_{uj} > \hat{x}_{ui} - 1. If the violating negative is
+// found after N trials, the rank of item i is estimated by \lfloor (|I|-1)/N \rfloor
+// and the pairwise update is scaled by L(rank) = \sum^{rank}_{k=1} 1/k, which is
+// normalized by L(|I|-1) to keep the learning rate comparable with BPR. No update is
+// made if no violating negative is found in maxSampled trials. The random generator is
+// seeded by the parameter "randState".
+func NewWARPOptimizer(maxSampled int) Optimizer {
+	return func(model OptModel, trainSet TrainSet, nEpochs int) {
+		// There are no negatives if there is only one item
+		if trainSet.ItemCount < 2 {
+			return
+		}
+		rng := rand.New(rand.NewSource(int64(paramsOf(model).GetInt("randState", int(time.Now().UnixNano())))))
+		positiveSet := make([]map[int]bool, trainSet.UserCount)
+		for u, b := range trainSet.UserRatings() {
+			positiveSet[u] = make(map[int]bool)
+			for _, d := range b {
+				positiveSet[u][d.Id] = true
+			}
+		}
+		// L(k) = \sum^k_{i=1} 1/i
+		loss := make([]float64, trainSet.ItemCount)
+		for k := 1; k < len(loss); k++ {
+			loss[k] = loss[k-1] + 1/float64(k)
+		}
+		for epoch := 0; epoch < nEpochs; epoch++ {
+			startEpoch(model, epoch)
+			for i := 0; i < trainSet.Length(); i++ {
+				// Select a positive
+				index := rng.Intn(trainSet.Length())
+				userId, posId, _ := trainSet.Index(index)
+				innerUserId := trainSet.ConvertUserId(userId)
+				innerPosId := trainSet.ConvertItemId(posId)
+				if len(positiveSet[innerUserId]) >= trainSet.ItemCount {
+					continue
+				}
+				posScore := model.Predict(userId, posId)
+				// Sample negatives until the margin is violated
+				for trial := 1; trial <= maxSampled; trial++ {
+					negId := rng.Intn(trainSet.ItemCount)
+					if _, exist := positiveSet[innerUserId][negId]; exist {
+						continue
+					}
+					outerNegId := trainSet.outerItemIds[negId]
+					if model.Predict(userId, outerNegId) > posScore-1 {
+						// Pairwise update weighted by the estimated rank
+						rank := (trainSet.ItemCount - 1) / trial
+						model.PairUpdate(loss[rank]/loss[len(loss)-1], innerUserId, innerPosId, negId)
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+// HogwildSGDOptimizer optimizes square error by parallel SGD in the Hogwild style[15].
+// In each epoch, ratings are shuffled and partitioned across goroutines, which update
+// shared model parameters without locks. States of adaptive updaters can't be shared
+// without locks, so the updater of the model must be "sgd". Parameters of the model:
+//   nJobs                - The number of goroutines. Default is the number of CPUs.
+//   randState            - The random seed of shuffling. Default is UNIX time step.
+//   hogwildDeterministic - Partitions are processed one by one so that results are
+//                          reproducible given the seed. Default is false.
+func HogwildSGDOptimizer(model OptModel, trainSet TrainSet, nEpochs int) {
+	checkHogwildUpdater(model)
+	rng := newHogwildRand(model)
+	hogwild(model, trainSet, nEpochs, rng.Int63(), func(jobId, index int) {
+		userId, itemId, rating := trainSet.Index(index)
+		innerUserId := trainSet.ConvertUserId(userId)
+		innerItemId := trainSet.ConvertItemId(itemId)
+		// Compute error
+		diff := rating - model.Predict(userId, itemId)
+		// Point-wise update
+		model.PointUpdate(diff, innerUserId, innerItemId)
+	})
+}
+
+// HogwildBPROptimizer optimizes BPR by parallel LearnBPR in the Hogwild style[15]. In
+// each epoch, positive ratings are shuffled and partitioned across goroutines, which
+// sample negatives and update shared model parameters without locks. Each goroutine
+// owns a NegativeSampler forked from the one created from parameters of the model. The
+// updater of the model must be "sgd". Parameters of the model are the same as
+// HogwildSGDOptimizer.
+func HogwildBPROptimizer(model OptModel, trainSet TrainSet, nEpochs int) {
+	checkHogwildUpdater(model)
+	rng := newHogwildRand(model)
+	nJobs := paramsOf(model).GetInt("nJobs", runtime.NumCPU())
+	sampler := NewNegativeSampler(paramsOf(model), model, trainSet, rng.Int63())
+	samplers := make([]NegativeSampler, nJobs)
+	for j := range samplers {
+		samplers[j] = sampler.Fork(rng.Int63())
+	}
+	hogwild(model, trainSet, nEpochs, rng.Int63(), func(jobId, index int) {
+		// Select a positive
+		userId, posId, _ := trainSet.Index(index)
+		innerUserId := trainSet.ConvertUserId(userId)
+		innerPosId := trainSet.ConvertItemId(posId)
+		// Select a negative
+		negId := samplers[jobId].Sample(innerUserId, innerPosId)
+		if negId < 0 {
+			return
+		}
+		outerNegId := trainSet.outerItemIds[negId]
+		diff := model.Predict(userId, posId) - model.Predict(userId, outerNegId)
+		grad := math.Exp(-diff) / (1.0 + math.Exp(-diff))
+		// Pairwise update
+		model.PairUpdate(grad, innerUserId, innerPosId, negId)
+	})
+}
+
+// Create the random generator of Hogwild optimizers seeded by the parameter "randState".
+func newHogwildRand(model OptModel) *rand.Rand {
+	return rand.New(rand.NewSource(int64(paramsOf(model).GetInt("randState", int(time.Now().UnixNano())))))
+}
+
+// Panic if a model uses an adaptive updater, whose states are written by every update.
+func checkHogwildUpdater(model OptModel) {
+	if method := paramsOf(model).GetString("updater", updaterSGD); method != updaterSGD {
+		panic("Hogwild optimizers: updater " + method + " isn't supported")
+	}
+}
+
+// Run a worker on shuffled samples partitioned across goroutines for nEpochs epochs.
+func hogwild(model OptModel, trainSet TrainSet, nEpochs int, seed int64, worker func(jobId, index int)) {
+	params := paramsOf(model)
+	nJobs := params.GetInt("nJobs", runtime.NumCPU())
+	deterministic := params.GetBool("hogwildDeterministic", false)
+	rng := rand.New(rand.NewSource(seed))
+	for epoch := 0; epoch < nEpochs; epoch++ {
+		startEpoch(model, epoch)
+		perm := rng.Perm(trainSet.Length())
+		job := func(jobId int) {
+			begin := len(perm) * jobId / nJobs
+			end := len(perm) * (jobId + 1) / nJobs
+			for _, index := range perm[begin:end] {
+				worker(jobId, index)
+			}
+		}
+		if deterministic {
+			for j := 0; j < nJobs; j++ {
+				job(j)
+			}
+		} else {
+			var wg sync.WaitGroup
+			wg.Add(nJobs)
+			for j := 0; j < nJobs; j++ {
+				go func(jobId int) {
+					job(jobId)
+					wg.Done()
+				}(j)
+			}
+			wg.Wait()
+		}
+	}
+}
+
+// ALSModel supports the alternating least squares optimizer.
+type ALSModel interface {
+	OptModel
+	// Factors returns user latent factors and item latent factors.
+	Factors() ([][]float64, [][]float64)
+	// Biases returns the global bias, user biases and item biases. Biases are nil if not used.
+	Biases() (*float64, []float64, []float64)
+	// Reg returns the regularization parameter.
+	Reg() float64
+}
+
+// ALSOptimizer optimizes an ALSModel by alternating least squares on square error.
+// The global bias is fixed to the mean of ratings. In each epoch, all user biases and
+// factors are solved with items fixed, then all item biases and factors are solved with
+// users fixed. The regularization parameter of a user (item) is scaled by the number
+// of its ratings. Users (items) are solved by the number of goroutines given by the
+// parameter "nJobs" of the model. The OptModel must be an ALSModel.
+func ALSOptimizer(model OptModel, trainSet TrainSet, nEpochs int) {
+	alsModel := toALSModel(model)
+	if globalBias, _, _ := alsModel.Biases(); globalBias != nil {
+		*globalBias = trainSet.GlobalMean
+	}
+	als(alsModel, trainSet, newRangeVector(trainSet.UserCount), newRangeVector(trainSet.ItemCount), nEpochs)
+}
+
+// Check whether an optimizer is ALSOptimizer.
+func isALSOptimizer(optimizer Optimizer) bool {
+	return reflect.ValueOf(optimizer).Pointer() == reflect.ValueOf(ALSOptimizer).Pointer()
+}
+
+// Update a fitted ALSModel with a train set of appended ratings (delta) by alternating
+// least squares. Only users and items in delta are solved from all their ratings in the
+// train set, while the global bias and other users and items are kept.
+func partialALS(model OptModel, trainSet TrainSet, delta TrainSet, nEpochs int) {
+	userSet, itemSet := make(map[int]bool), make(map[int]bool)
+	users, items := []int{}, []int{}
+	for i := 0; i < delta.Length(); i++ {
+		userId, itemId, _ := delta.Index(i)
+		if innerUserId := trainSet.ConvertUserId(userId); !userSet[innerUserId] {
+			userSet[innerUserId] = true
+			users = append(users, innerUserId)
+		}
+		if innerItemId := trainSet.ConvertItemId(itemId); !itemSet[innerItemId] {
+			itemSet[innerItemId] = true
+			items = append(items, innerItemId)
+		}
+	}
+	als(toALSModel(model), trainSet, users, items, nEpochs)
+}
+
+// Convert an OptModel to an ALSModel. Panic if the model doesn't implement ALSModel.
+func toALSModel(model OptModel) ALSModel {
+	alsModel, ok := model.(ALSModel)
+	if !ok {
+		panic("ALSOptimizer: model doesn't implement ALSModel")
+	}
+	return alsModel
+}
+
+// Solve given users and items alternately from their ratings in a train set for nEpochs epochs.
+func als(model ALSModel, trainSet TrainSet, users, items []int, nEpochs int) {
+	nJobs := paramsOf(model).GetInt("nJobs", runtime.NumCPU())
+	userFactor, itemFactor := model.Factors()
+	globalBias, userBias, itemBias := model.Biases()
+	reg := model.Reg()
+	mean := 0.0
+	if globalBias != nil {
+		mean = *globalBias
+	}
+	userRatings := trainSet.UserRatings()
+	itemRatings := trainSet.ItemRatings()
+	for epoch := 0; epoch < nEpochs; epoch++ {
+		// Solve users with items fixed
+		parallel(len(users), nJobs, func(begin, end int) {
+			for _, u := range users[begin:end] {
+				leastSquares(userFactor[u], biasOf(userBias, u), userRatings[u], itemFactor, itemBias, mean, reg)
+			}
+		})
+		// Solve items with users fixed
+		parallel(len(items), nJobs, func(begin, end int) {
+			for _, i := range items[begin:end] {
+				leastSquares(itemFactor[i], biasOf(itemBias, i), itemRatings[i], userFactor, userBias, mean, reg)
+			}
+		})
+	}
+}
+
+// Solve the factor p and the bias b (optional) of a user (item) by ridge regression:
+//
+//   \min_{b,p} \sum_{i \in I} (r_i - μ - b_i - b - p^Tq_i)^2 + λ|I|(b^2 + ||p||^2)
+//
+// where q_i and b_i are factors and biases (optional) of rated items (users).
+func leastSquares(dstFactor []float64, dstBias *float64, ratings []IdRating, factors [][]float64, biases []float64,
+	globalBias float64, reg float64) {
+	if len(ratings) == 0 {
+		return
+	}
+	// The dimension of [b, p]
+	dim := len(dstFactor)
+	offset := 0
+	if dstBias != nil {
+		dim++
+		offset = 1
+	}
+	// A = Z^TZ + λ|I|I, y = Z^Tt where z_i = [1, q_i] and t_i = r_i - μ - b_i
+	z := mat.NewDense(len(ratings), dim, nil)
+	t := mat.NewVecDense(len(ratings), nil)
+	for j, ir := range ratings {
+		target := ir.Rating - globalBias
+		if dstBias != nil {
+			z.Set(j, 0, 1)
+		}
+		if biases != nil {
+			target -= biases[ir.Id]
+		}
+		for f, v := range factors[ir.Id] {
+			z.Set(j, offset+f, v)
+		}
+		t.SetVec(j, target)
+	}
+	a := mat.NewSymDense(dim, nil)
+	a.SymOuterK(1, z.T())
+	y := mat.NewVecDense(dim, nil)
+	y.MulVec(z.T(), t)
+	for f := 0; f < dim; f++ {
+		a.SetSym(f, f, a.At(f, f)+reg*float64(len(ratings)))
+	}
+	// Solve linear equations
+	var chol mat.Cholesky
+	if !chol.Factorize(a) {
+		return
+	}
+	x := mat.NewVecDense(dim, nil)
+	if err := chol.SolveVec(x, y); err != nil {
+		return
+	}
+	if dstBias != nil {
+		*dstBias = x.AtVec(0)
+	}
+	for f := range dstFactor {
+		dstFactor[f] = x.AtVec(offset + f)
+	}
+}
+
+// Get the pointer of a bias. Return nil if biases are not used.
+func biasOf(biases []float64, id int) *float64 {
+	if biases == nil {
+		return nil
+	}
+	return &biases[id]
+}
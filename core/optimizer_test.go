@@ -0,0 +1,152 @@
+package core
+
+import (
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/stat"
+	"math"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+// Generate a synthetic train set with random ratings.
+func newSyntheticTrainSet(nUsers, nItems, nRatings int, seed int64) TrainSet {
+	rng := rand.New(rand.NewSource(seed))
+	users := make([]int, nRatings)
+	items := make([]int, nRatings)
+	ratings := make([]float64, nRatings)
+	for i := range ratings {
+		users[i] = rng.Intn(nUsers)
+		items[i] = rng.Intn(nItems)
+		ratings[i] = float64(rng.Intn(5) + 1)
+	}
+	return NewTrainSet(NewRawDataSet(users, items, ratings))
+}
+
+func TestHogwildSGDOptimizer_Deterministic(t *testing.T) {
+	trainSet := newSyntheticTrainSet(100, 100, 2000, 0)
+	params := Parameters{
+		"randState": 0,
+		"nFactors":  10,
+		"nEpochs":   5,
+		"optimizer":            HogwildSGDOptimizer,
+		"nJobs":                4,
+		"hogwildDeterministic": true,
+	}
+	svd1 := NewSVD(params)
+	svd1.Fit(trainSet)
+	svd2 := NewSVD(params)
+	svd2.Fit(trainSet)
+	for i := range svd1.UserFactor {
+		if !floats.Equal(svd1.UserFactor[i], svd2.UserFactor[i]) {
+			t.Fatalf("user factors differ in the deterministic mode")
+		}
+	}
+	for i := range svd1.ItemFactor {
+		if !floats.Equal(svd1.ItemFactor[i], svd2.ItemFactor[i]) {
+			t.Fatalf("item factors differ in the deterministic mode")
+		}
+	}
+}
+
+func TestHogwildSGDOptimizer_GridSearchCV(t *testing.T) {
+	// Settings of Hogwild optimizers are searched as parameters
+	dataSet := newSyntheticTrainSet(100, 100, 2000, 0).DataSet
+	results := GridSearchCV(NewSVD(nil), dataSet, ParameterGrid{
+		"optimizer": {HogwildSGDOptimizer},
+		"nJobs":     {1, 4},
+		"nEpochs":   {5},
+	}, []Evaluator{RMSE}, 5, 0, 1)
+	if n := len(results[0].AllParams); n != 2 {
+		t.Fatalf("Number of searched parameters (%d) != 2", n)
+	}
+	for _, rmse := range results[0].CVResults {
+		if math.IsNaN(stat.Mean(rmse.Tests, nil)) {
+			t.Fatalf("expect a number, get NaN")
+		}
+	}
+}
+
+func TestHogwildSGDOptimizer_Adam(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expect a panic with an adaptive updater")
+		}
+	}()
+	svd := NewSVD(Parameters{
+		"updater":   "adam",
+		"optimizer": HogwildSGDOptimizer,
+	})
+	svd.Fit(newSyntheticTrainSet(100, 100, 2000, 0))
+}
+
+func TestALSOptimizer_NJobs(t *testing.T) {
+	trainSet := newSyntheticTrainSet(100, 100, 2000, 0)
+	params := Parameters{
+		"randState": 0,
+		"nFactors":  10,
+		"nEpochs":   5,
+		"optimizer": ALSOptimizer,
+		"nJobs":     1,
+	}
+	svd1 := NewSVD(params)
+	svd1.Fit(trainSet)
+	params["nJobs"] = 4
+	svd2 := NewSVD(params)
+	svd2.Fit(trainSet)
+	for i := range svd1.UserFactor {
+		if !floats.Equal(svd1.UserFactor[i], svd2.UserFactor[i]) {
+			t.Fatalf("user factors differ with different nJobs")
+		}
+	}
+	for i := range svd1.ItemFactor {
+		if !floats.Equal(svd1.ItemFactor[i], svd2.ItemFactor[i]) {
+			t.Fatalf("item factors differ with different nJobs")
+		}
+	}
+}
+
+func TestWARPOptimizer_OneItem(t *testing.T) {
+	trainSet := NewTrainSet(NewRawDataSet([]int{0, 1}, []int{0, 0}, []float64{1, 1}))
+	svd := NewSVD(Parameters{
+		"randState": 0,
+		"nFactors":  10,
+		"optimizer": WARPOptimizer,
+	})
+	svd.Fit(trainSet)
+	if pred := svd.Predict(0, 0); math.IsNaN(pred) {
+		t.Fatalf("expect a number, get %v", pred)
+	}
+}
+
+// Benchmark optimizers on a synthetic data set. The speedup of Hogwild optimizers
+// could be measured by: go test -bench Optimizer -cpu 1,2,4,8
+func benchmarkOptimizer(b *testing.B, optimizer Optimizer) {
+	trainSet := newSyntheticTrainSet(10000, 5000, 500000, 0)
+	svd := NewSVD(Parameters{
+		"randState": 0,
+		"nEpochs":   1,
+		"nJobs":     runtime.GOMAXPROCS(0),
+		"optimizer": optimizer,
+	})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		svd.Fit(trainSet)
+	}
+}
+
+func BenchmarkSGDOptimizer(b *testing.B) {
+	benchmarkOptimizer(b, SGDOptimizer)
+}
+
+func BenchmarkHogwildSGDOptimizer(b *testing.B) {
+	benchmarkOptimizer(b, HogwildSGDOptimizer)
+}
+
+func BenchmarkBPROptimizer(b *testing.B) {
+	benchmarkOptimizer(b, BPROptimizer)
+}
+
+func BenchmarkHogwildBPROptimizer(b *testing.B) {
+	benchmarkOptimizer(b, HogwildBPROptimizer)
+}
@@ -0,0 +1,274 @@
+package core
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Negative sampler
+const (
+	uniformSampler    = "uniform"
+	popularitySampler = "popularity"
+	inBatchSampler    = "inbatch"
+	hardSampler       = "hard"
+)
+
+// NegativeSampler samples negative items for pairwise training. Items rated by
+// a user are never sampled as negatives of the user.
+type NegativeSampler interface {
+	// Sample a negative item (inner ID) for a user (inner ID) given a positive
+	// item (inner ID). -1 is returned if all items are rated by the user.
+	Sample(innerUserId, innerPosId int) int
+	// Fork creates a sampler sharing training data with a new random seed,
+	// which is used by concurrent optimizers.
+	Fork(seed int64) NegativeSampler
+}
+
+// NewNegativeSampler creates a negative sampler selected by parameters:
+//   sampler            - The negative sampler: "uniform", "popularity", "inbatch" or "hard".
+//                        Default is "uniform".
+//   popularityExponent - The popularity sampler samples a item with probability proportional
+//                        to the number of ratings to the power of popularityExponent. Default is 1.
+//   samplerBatchSize   - The in-batch sampler samples negatives from positives of the last
+//                        samplerBatchSize samples. Default is 100.
+//   nCandidates        - The hard sampler samples nCandidates negatives uniformly and returns
+//                        the one with the highest score by the model. Default is 5.
+func NewNegativeSampler(params Parameters, model Model, trainSet TrainSet, seed int64) NegativeSampler {
+	data := newSamplerData(trainSet)
+	rng := rand.New(rand.NewSource(seed))
+	switch name := params.GetString("sampler", uniformSampler); name {
+	case uniformSampler:
+		return &UniformSampler{data, rng}
+	case popularitySampler:
+		exponent := params.GetFloat64("popularityExponent", 1)
+		weights := make([]float64, trainSet.ItemCount)
+		for i, irs := range trainSet.ItemRatings() {
+			weights[i] = math.Pow(float64(len(irs)), exponent)
+		}
+		return &PopularitySampler{data, newAliasTable(weights), rng}
+	case inBatchSampler:
+		batchSize := params.GetInt("samplerBatchSize", 100)
+		return &InBatchSampler{data, make([]int, 0, batchSize), 0, rng}
+	case hardSampler:
+		nCandidates := params.GetInt("nCandidates", 5)
+		return &HardSampler{data, model, nCandidates, rng}
+	default:
+		panic("NewNegativeSampler: unknown sampler " + name)
+	}
+}
+
+// Training data shared by samplers.
+type samplerData struct {
+	trainSet  TrainSet
+	positives [][]int // Sorted items rated by each user
+}
+
+func newSamplerData(trainSet TrainSet) *samplerData {
+	data := &samplerData{trainSet: trainSet}
+	data.positives = make([][]int, trainSet.UserCount)
+	for u, irs := range trainSet.UserRatings() {
+		data.positives[u] = make([]int, len(irs))
+		for i, ir := range irs {
+			data.positives[u][i] = ir.Id
+		}
+		sort.Ints(data.positives[u])
+	}
+	return data
+}
+
+// Check whether an item is rated by a user.
+func (data *samplerData) isPositive(innerUserId, innerItemId int) bool {
+	positives := data.positives[innerUserId]
+	i := sort.SearchInts(positives, innerItemId)
+	return i < len(positives) && positives[i] == innerItemId
+}
+
+// Check whether all items are rated by a user.
+func (data *samplerData) isFull(innerUserId int) bool {
+	return len(data.positives[innerUserId]) >= data.trainSet.ItemCount
+}
+
+// Sample a negative uniformly.
+func (data *samplerData) uniform(rng *rand.Rand, innerUserId int) int {
+	if data.isFull(innerUserId) {
+		return -1
+	}
+	for {
+		negId := rng.Intn(data.trainSet.ItemCount)
+		if !data.isPositive(innerUserId, negId) {
+			return negId
+		}
+	}
+}
+
+/* Uniform Sampler */
+
+// UniformSampler samples negatives uniformly.
+type UniformSampler struct {
+	*samplerData
+	rng *rand.Rand
+}
+
+// Sample a negative.
+func (sampler *UniformSampler) Sample(innerUserId, innerPosId int) int {
+	return sampler.uniform(sampler.rng, innerUserId)
+}
+
+// Fork a sampler.
+func (sampler *UniformSampler) Fork(seed int64) NegativeSampler {
+	return &UniformSampler{sampler.samplerData, rand.New(rand.NewSource(seed))}
+}
+
+/* Popularity Sampler */
+
+// PopularitySampler samples negatives with probabilities proportional to the
+// popularity of items. Items are drawn by the alias method in O(1) time.
+type PopularitySampler struct {
+	*samplerData
+	table *aliasTable
+	rng   *rand.Rand
+}
+
+// Sample a negative.
+func (sampler *PopularitySampler) Sample(innerUserId, innerPosId int) int {
+	if sampler.isFull(innerUserId) {
+		return -1
+	}
+	for {
+		negId := sampler.table.sample(sampler.rng)
+		if !sampler.isPositive(innerUserId, negId) {
+			return negId
+		}
+	}
+}
+
+// Fork a sampler.
+func (sampler *PopularitySampler) Fork(seed int64) NegativeSampler {
+	return &PopularitySampler{sampler.samplerData, sampler.table, rand.New(rand.NewSource(seed))}
+}
+
+/* In-batch Sampler */
+
+// InBatchSampler samples negatives from positives of the last batch of samples,
+// which follows the popularity of items in the training stream. A negative is
+// sampled uniformly if no item in the batch is negative for the user.
+type InBatchSampler struct {
+	*samplerData
+	batch []int // Positives in the batch
+	next  int   // The next position in the batch to be replaced
+	rng   *rand.Rand
+}
+
+// Sample a negative.
+func (sampler *InBatchSampler) Sample(innerUserId, innerPosId int) int {
+	// Add the positive to the batch
+	if len(sampler.batch) < cap(sampler.batch) {
+		sampler.batch = append(sampler.batch, innerPosId)
+	} else if len(sampler.batch) > 0 {
+		sampler.batch[sampler.next] = innerPosId
+		sampler.next = (sampler.next + 1) % len(sampler.batch)
+	}
+	// Sample a negative from the batch
+	for trial := 0; trial < len(sampler.batch); trial++ {
+		negId := sampler.batch[sampler.rng.Intn(len(sampler.batch))]
+		if !sampler.isPositive(innerUserId, negId) {
+			return negId
+		}
+	}
+	return sampler.uniform(sampler.rng, innerUserId)
+}
+
+// Fork a sampler.
+func (sampler *InBatchSampler) Fork(seed int64) NegativeSampler {
+	return &InBatchSampler{sampler.samplerData, make([]int, 0, cap(sampler.batch)), 0, rand.New(rand.NewSource(seed))}
+}
+
+/* Hard Sampler */
+
+// HardSampler samples several negatives uniformly and returns the one with
+// the highest score by the model, which is the hardest to be distinguished
+// from positives.
+type HardSampler struct {
+	*samplerData
+	model       Model
+	nCandidates int
+	rng         *rand.Rand
+}
+
+// Sample a negative.
+func (sampler *HardSampler) Sample(innerUserId, innerPosId int) int {
+	userId := sampler.trainSet.OuterUserId(innerUserId)
+	negId, maxScore := -1, math.Inf(-1)
+	for i := 0; i < sampler.nCandidates; i++ {
+		candidate := sampler.uniform(sampler.rng, innerUserId)
+		if candidate < 0 {
+			return -1
+		}
+		if score := sampler.model.Predict(userId, sampler.trainSet.OuterItemId(candidate)); score > maxScore {
+			negId, maxScore = candidate, score
+		}
+	}
+	return negId
+}
+
+// Fork a sampler.
+func (sampler *HardSampler) Fork(seed int64) NegativeSampler {
+	return &HardSampler{sampler.samplerData, sampler.model, sampler.nCandidates, rand.New(rand.NewSource(seed))}
+}
+
+/* Utils */
+
+// Alias table for sampling from a discrete distribution in O(1) time.
+type aliasTable struct {
+	prob  []float64
+	alias []int
+}
+
+// Create an alias table from non-negative weights by Vose's method.
+func newAliasTable(weights []float64) *aliasTable {
+	n := len(weights)
+	table := &aliasTable{make([]float64, n), make([]int, n)}
+	sum := 0.0
+	for _, w := range weights {
+		sum += w
+	}
+	// Scale probabilities to mean 1
+	scaled := make([]float64, n)
+	small, large := make([]int, 0, n), make([]int, 0, n)
+	for i, w := range weights {
+		scaled[i] = w * float64(n) / sum
+		if scaled[i] < 1 {
+			small = append(small, i)
+		} else {
+			large = append(large, i)
+		}
+	}
+	// Pair small and large columns
+	for len(small) > 0 && len(large) > 0 {
+		s, l := small[len(small)-1], large[len(large)-1]
+		small = small[:len(small)-1]
+		table.prob[s] = scaled[s]
+		table.alias[s] = l
+		scaled[l] += scaled[s] - 1
+		if scaled[l] < 1 {
+			large = large[:len(large)-1]
+			small = append(small, l)
+		}
+	}
+	// Columns left are full because of numeric error
+	for _, i := range append(small, large...) {
+		table.prob[i] = 1
+		table.alias[i] = i
+	}
+	return table
+}
+
+// Sample an index.
+func (table *aliasTable) sample(rng *rand.Rand) int {
+	i := rng.Intn(len(table.prob))
+	if rng.Float64() < table.prob[i] {
+		return i
+	}
+	return table.alias[i]
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func testSamplerNegatives(t *testing.T, params Parameters) {
+	trainSet := newSyntheticTrainSet(50, 100, 2000, 0)
+	sampler := NewNegativeSampler(params, NewItemPop(nil), trainSet, 0)
+	for u, irs := range trainSet.UserRatings() {
+		for _, ir := range irs {
+			negId := sampler.Sample(u, ir.Id)
+			if negId < 0 || negId >= trainSet.ItemCount {
+				t.Fatalf("invalid negative %d", negId)
+			}
+			for _, pos := range irs {
+				if pos.Id == negId {
+					t.Fatalf("positive %d is sampled as a negative of user %d", negId, u)
+				}
+			}
+		}
+	}
+}
+
+func testSamplerSeed(t *testing.T, params Parameters) {
+	trainSet := newSyntheticTrainSet(50, 100, 2000, 0)
+	sampler1 := NewNegativeSampler(params, NewItemPop(nil), trainSet, 0)
+	sampler2 := NewNegativeSampler(params, NewItemPop(nil), trainSet, 0).Fork(0).Fork(1)
+	sampler3 := sampler1.Fork(1)
+	for i := 0; i < 1000; i++ {
+		u, pos := i%trainSet.UserCount, i%trainSet.ItemCount
+		if neg2, neg3 := sampler2.Sample(u, pos), sampler3.Sample(u, pos); neg2 != neg3 {
+			t.Fatalf("samplers with the same seed return %d != %d", neg2, neg3)
+		}
+	}
+}
+
+func TestUniformSampler(t *testing.T) {
+	testSamplerNegatives(t, Parameters{"sampler": "uniform"})
+	testSamplerSeed(t, Parameters{"sampler": "uniform"})
+}
+
+func TestPopularitySampler(t *testing.T) {
+	testSamplerNegatives(t, Parameters{"sampler": "popularity"})
+	testSamplerSeed(t, Parameters{"sampler": "popularity"})
+}
+
+func TestInBatchSampler(t *testing.T) {
+	testSamplerNegatives(t, Parameters{"sampler": "inbatch", "samplerBatchSize": 10})
+	testSamplerSeed(t, Parameters{"sampler": "inbatch", "samplerBatchSize": 10})
+	// Negatives are sampled from the batch
+	trainSet := NewTrainSet(NewRawDataSet([]int{0, 1, 2}, []int{0, 1, 2}, []float64{1, 1, 1}))
+	sampler := NewNegativeSampler(Parameters{"sampler": "inbatch", "samplerBatchSize": 2}, nil, trainSet, 0)
+	counts := make([]int, trainSet.ItemCount)
+	for i := 0; i < 1000; i++ {
+		sampler.Sample(1, 1)
+		counts[sampler.Sample(0, 0)]++
+	}
+	if counts[1] < 2*counts[2] {
+		t.Fatalf("negatives aren't sampled from the batch: %v", counts)
+	}
+}
+
+func TestHardSampler(t *testing.T) {
+	testSamplerNegatives(t, Parameters{"sampler": "hard"})
+	testSamplerSeed(t, Parameters{"sampler": "hard"})
+	// The hardest negative is the most popular one
+	trainSet := newSyntheticTrainSet(50, 100, 2000, 0)
+	pop := NewItemPop(nil)
+	pop.Fit(trainSet)
+	sampler := NewNegativeSampler(Parameters{"sampler": "hard", "nCandidates": trainSet.ItemCount * 10}, pop, trainSet, 0)
+	for u, irs := range trainSet.UserRatings() {
+		negId := sampler.Sample(u, irs[0].Id)
+		negScore := pop.Predict(trainSet.OuterUserId(u), trainSet.OuterItemId(negId))
+		for i := 0; i < trainSet.ItemCount; i++ {
+			score := pop.Predict(trainSet.OuterUserId(u), trainSet.OuterItemId(i))
+			if !sampler.(*HardSampler).isPositive(u, i) && score > negScore {
+				t.Fatalf("negative %d isn't the hardest negative", negId)
+			}
+		}
+	}
+}
+
+func TestAliasTable(t *testing.T) {
+	weights := []float64{1, 2, 3, 4, 0}
+	table := newAliasTable(weights)
+	rng := rand.New(rand.NewSource(0))
+	counts := make([]float64, len(weights))
+	const n = 100000
+	for i := 0; i < n; i++ {
+		counts[table.sample(rng)]++
+	}
+	for i, w := range weights {
+		if p := counts[i] / n; math.Abs(p-w/10) > 0.01 {
+			t.Fatalf("P(%d) = %.3f != %.3f", i, p, w/10)
+		}
+	}
+}
@@ -7,6 +7,21 @@ import (
 // Similarity computes the similarity between two lists of rating history.
 type Similarity func(SortedIdRatings, SortedIdRatings) float64
 
+// BaselineSimilarity computes the similarity between two lists of rating
+// history with baselines removed, that is, residuals r_{ui} - b_{ui} where
+// b_{ui} = μ + b_u + b_i is estimated by a fitted BaseLine model. The
+// similarity is shrunk by the number of common ratings (co-support).
+type BaselineSimilarity func(a SortedIdRatings, b SortedIdRatings, shrinkage int) float64
+
+// Check whether a parameter is a BaselineSimilarity.
+func isBaselineSim(val interface{}) bool {
+	switch val.(type) {
+	case BaselineSimilarity, func(SortedIdRatings, SortedIdRatings, int) float64:
+		return true
+	}
+	return false
+}
+
 // Cosine computes the cosine similarity between a pair of users (or items).
 func Cosine(a SortedIdRatings, b SortedIdRatings) float64 {
 	m, n, l, ptr := .0, .0, .0, 0
@@ -73,3 +88,31 @@ func Pearson(a SortedIdRatings, b SortedIdRatings) float64 {
 	}
 	return l / (math.Sqrt(m) * math.Sqrt(n))
 }
+
+// PearsonBaseline computes the (shrunk) Pearson correlation coefficient between a pair
+// of users (or items) using baselines for centering instead of means. The shrinkage
+// parameter helps to avoid over-fitting when only few ratings are available:
+//
+//   \frac{|I_{uv}| - 1}{|I_{uv}| - 1 + shrinkage} \cdot \frac{\sum_{i \in I_{uv}} (r_{ui} - b_{ui}) \cdot (r_{vi} - b_{vi})}
+//   {\sqrt{\sum_{i \in I_{uv}} (r_{ui} - b_{ui})^2} \cdot \sqrt{\sum_{i \in I_{uv}} (r_{vi} - b_{vi})^2}}
+//
+// where I_{uv} is the set of items rated by both user u and user v.
+func PearsonBaseline(a SortedIdRatings, b SortedIdRatings, shrinkage int) float64 {
+	m, n, l, count, ptr := .0, .0, .0, .0, 0
+	for _, ir := range a.data {
+		for ptr < len(b.data) && b.data[ptr].Id < ir.Id {
+			ptr++
+		}
+		if ptr < len(b.data) && b.data[ptr].Id == ir.Id {
+			jr := b.data[ptr]
+			m += ir.Rating * ir.Rating
+			n += jr.Rating * jr.Rating
+			l += ir.Rating * jr.Rating
+			count++
+		}
+	}
+	if count < 2 {
+		return 0
+	}
+	return (count - 1) / (count - 1 + float64(shrinkage)) * l / (math.Sqrt(m) * math.Sqrt(n))
+}
@@ -57,3 +57,26 @@ func TestPearson(t *testing.T) {
 		t.Fatal(sim, "!=", 0.0)
 	}
 }
+
+func TestPearsonBaseline(t *testing.T) {
+	a := NewSortedIdRatings([]IdRating{
+		{1, 1},
+		{2, -1},
+		{3, 2},
+	})
+	b := NewSortedIdRatings([]IdRating{
+		{0, 0},
+		{1, 2},
+		{2, -2},
+	})
+	// Without shrinkage
+	sim := PearsonBaseline(a, b, 0)
+	if math.Abs(sim-1) > epsilon {
+		t.Fatal(sim, "!=", 1)
+	}
+	// With shrinkage
+	sim = PearsonBaseline(a, b, 1)
+	if math.Abs(sim-0.5) > epsilon {
+		t.Fatal(sim, "!=", 0.5)
+	}
+}
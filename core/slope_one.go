@@ -4,7 +4,27 @@ import (
 	"runtime"
 )
 
-// SlopeOne, a collaborative filtering algorithm[1].
+// SlopeOne type
+const (
+	weighted = "weighted"
+	biPolar  = "bipolar"
+)
+
+// SlopeOne, a collaborative filtering algorithm[1]. The prediction of the
+// basic SlopeOne is the mean of user u plus the average deviation between
+// item i and items rated by user u:
+//
+//   P(u)_i = \bar{u} + \frac{1}{|R_u|} \sum_{j \in R_u} dev_{i,j}
+//
+// The weighted SlopeOne weights deviations by the number of common raters c_{i,j}:
+//
+//   P(u)_i = \frac{\sum_{j \in R_u} (dev_{i,j} + u_j) c_{i,j}}{\sum_{j \in R_u} c_{i,j}}
+//
+// The bi-polar SlopeOne splits ratings into the like set (ratings above the
+// user mean) and the dislike set (ratings below the user mean). Deviations are
+// computed from users who like (dislike) both items and items in the like
+// (dislike) set of user u are used to predict in the same way as the weighted
+// SlopeOne.
 //
 // [1] Lemire, Daniel, and Anna Maclachlan. "Slope one predictors
 // for online rating-based collaborative filtering." Proceedings
@@ -12,10 +32,14 @@ import (
 // Society for Industrial and Applied Mathematics, 2005.
 type SlopeOne struct {
 	Base
-	GlobalMean  float64
-	UserRatings [][]IdRating
-	UserMeans   []float64
-	Dev         [][]float64 // The average differences between the LeftRatings of i and those of j
+	SlopeOneType string
+	GlobalMean   float64
+	UserRatings  [][]IdRating
+	UserMeans    []float64
+	Dev          [][]float64 // The average differences between the LeftRatings of i and those of j
+	Count        [][]int     // The number of users rated both i and j
+	DislikeDev   [][]float64 // Bi-polar SlopeOne: Dev and Count are computed from the like set
+	DislikeCount [][]int     // Bi-polar SlopeOne: the number of users disliked both i and j
 }
 
 // NewSlopOne creates a slop one model. Parameters:
@@ -23,6 +47,25 @@ type SlopeOne struct {
 func NewSlopOne(params Parameters) *SlopeOne {
 	so := new(SlopeOne)
 	so.Params = params
+	so.SlopeOneType = so.Params.GetString("type", basic)
+	return so
+}
+
+// NewWeightedSlopeOne creates a weighted slope one model. Parameters:
+//	 nJobs		- The number of goroutines to compute deviation. Default is the number of CPUs.
+func NewWeightedSlopeOne(params Parameters) *SlopeOne {
+	so := new(SlopeOne)
+	so.Params = params
+	so.SlopeOneType = so.Params.GetString("type", weighted)
+	return so
+}
+
+// NewBiPolarSlopeOne creates a bi-polar slope one model. Parameters:
+//	 nJobs		- The number of goroutines to compute deviation. Default is the number of CPUs.
+func NewBiPolarSlopeOne(params Parameters) *SlopeOne {
+	so := new(SlopeOne)
+	so.Params = params
+	so.SlopeOneType = so.Params.GetString("type", biPolar)
 	return so
 }
 
@@ -31,14 +74,30 @@ func (so *SlopeOne) Predict(userId, itemId int) float64 {
 	// Convert to inner Id
 	innerUserId := so.Data.ConvertUserId(userId)
 	innerItemId := so.Data.ConvertItemId(itemId)
-	prediction := 0.0
-	if innerUserId != NewId {
-		prediction = so.UserMeans[innerUserId]
-	} else {
-		prediction = so.GlobalMean
+	if innerUserId == NewId {
+		return so.GlobalMean
 	}
-	if innerItemId != NewId {
-		sum, count := 0.0, 0.0
+	prediction := so.UserMeans[innerUserId]
+	if innerItemId == NewId {
+		return prediction
+	}
+	sum, count := 0.0, 0.0
+	switch so.SlopeOneType {
+	case weighted, biPolar:
+		for _, ir := range so.UserRatings[innerUserId] {
+			dev, c := so.Dev[innerItemId][ir.Id], float64(so.Count[innerItemId][ir.Id])
+			if so.SlopeOneType == biPolar && ir.Rating < so.UserMeans[innerUserId] {
+				dev, c = so.DislikeDev[innerItemId][ir.Id], float64(so.DislikeCount[innerItemId][ir.Id])
+			} else if so.SlopeOneType == biPolar && ir.Rating == so.UserMeans[innerUserId] {
+				continue
+			}
+			sum += (dev + ir.Rating) * c
+			count += c
+		}
+		if count > 0 {
+			prediction = sum / count
+		}
+	default:
 		for _, ir := range so.UserRatings[innerUserId] {
 			sum += so.Dev[innerItemId][ir.Id]
 			count++
@@ -52,18 +111,45 @@ func (so *SlopeOne) Predict(userId, itemId int) float64 {
 
 // Fit a SlopeOne model.
 func (so *SlopeOne) Fit(trainSet TrainSet) {
-	nJobs := runtime.NumCPU()
+	nJobs := so.Params.GetInt("nJobs", runtime.NumCPU())
 	so.Data = trainSet
 	so.GlobalMean = trainSet.GlobalMean
 	so.UserRatings = trainSet.UserRatings()
 	so.UserMeans = means(so.UserRatings)
-	so.Dev = newZeroMatrix(trainSet.ItemCount, trainSet.ItemCount)
 	itemRatings := trainSet.ItemRatings()
+	if so.SlopeOneType == biPolar {
+		// Split ratings into the like set and the dislike set
+		likeRatings := make([][]IdRating, len(itemRatings))
+		dislikeRatings := make([][]IdRating, len(itemRatings))
+		for i := range itemRatings {
+			for _, ur := range itemRatings[i] {
+				if ur.Rating > so.UserMeans[ur.Id] {
+					likeRatings[i] = append(likeRatings[i], ur)
+				} else if ur.Rating < so.UserMeans[ur.Id] {
+					dislikeRatings[i] = append(dislikeRatings[i], ur)
+				}
+			}
+		}
+		so.Dev, so.Count = deviations(likeRatings, nJobs)
+		so.DislikeDev, so.DislikeCount = deviations(dislikeRatings, nJobs)
+	} else {
+		so.Dev, so.Count = deviations(itemRatings, nJobs)
+	}
+}
+
+// Compute average differences between ratings of each pair of items and the
+// numbers of users rated both items.
+func deviations(itemRatings [][]IdRating, nJobs int) ([][]float64, [][]int) {
+	dev := newZeroMatrix(len(itemRatings), len(itemRatings))
+	counts := make([][]int, len(itemRatings))
+	for i := range counts {
+		counts[i] = make([]int, len(itemRatings))
+	}
 	sorts(itemRatings)
 	parallel(len(itemRatings), nJobs, func(begin, end int) {
 		for i := begin; i < end; i++ {
 			for j := 0; j < i; j++ {
-				count, sum, ptr := 0.0, 0.0, 0
+				count, sum, ptr := 0, 0.0, 0
 				// Find common user's ratings
 				for k := 0; k < len(itemRatings[i]) && ptr < len(itemRatings[j]); k++ {
 					ur := itemRatings[i][k]
@@ -76,10 +162,13 @@ func (so *SlopeOne) Fit(trainSet TrainSet) {
 					}
 				}
 				if count > 0 {
-					so.Dev[i][j] = sum / count
-					so.Dev[j][i] = -so.Dev[i][j]
+					dev[i][j] = sum / float64(count)
+					dev[j][i] = -dev[i][j]
+					counts[i][j] = count
+					counts[j][i] = count
 				}
 			}
 		}
 	})
+	return dev, counts
 }
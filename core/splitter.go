@@ -38,33 +38,22 @@ func NewUserLOOSplitter(repeat int) Splitter {
 		testFolds := make([]DataSet, repeat)
 		rand.Seed(seed)
 		trainSet := NewTrainSet(dataSet)
+		userIndices := trainSet.UserIndices()
 		for i := 0; i < repeat; i++ {
-			trainUsers, trainItems, trainRatings :=
-				make([]int, 0, trainSet.Length()-trainSet.UserCount),
-				make([]int, 0, trainSet.Length()-trainSet.UserCount),
-				make([]float64, 0, trainSet.Length()-trainSet.UserCount)
-			testUsers, testItems, testRatings :=
-				make([]int, 0, trainSet.UserCount),
-				make([]int, 0, trainSet.UserCount),
-				make([]float64, 0, trainSet.UserCount)
-			for innerUserId, irs := range trainSet.UserRatings() {
-				userId := trainSet.outerUserIds[innerUserId]
-				out := rand.Intn(len(irs))
-				for index, ir := range irs {
-					itemId := trainSet.outerItemIds[ir.Id]
-					if index == out {
-						testUsers = append(testUsers, userId)
-						testItems = append(testItems, itemId)
-						testRatings = append(testRatings, ir.Rating)
+			trainIndex := make([]int, 0, trainSet.Length()-trainSet.UserCount)
+			testIndex := make([]int, 0, trainSet.UserCount)
+			for _, indices := range userIndices {
+				out := rand.Intn(len(indices))
+				for j, index := range indices {
+					if j == out {
+						testIndex = append(testIndex, index)
 					} else {
-						trainUsers = append(trainUsers, userId)
-						trainItems = append(trainItems, itemId)
-						trainRatings = append(trainRatings, ir.Rating)
+						trainIndex = append(trainIndex, index)
 					}
 				}
 			}
-			trainFolds[i] = NewTrainSet(NewRawDataSet(trainUsers, trainItems, trainRatings))
-			testFolds[i] = NewRawDataSet(testUsers, testItems, testRatings)
+			trainFolds[i] = NewTrainSet(dataSet.SubSet(trainIndex))
+			testFolds[i] = dataSet.SubSet(testIndex)
 		}
 		return trainFolds, testFolds
 	}
@@ -79,45 +68,31 @@ func NewUserKeepNSplitter(repeat int, n int, testRatio float64) Splitter {
 		testFolds := make([]DataSet, repeat)
 		rand.Seed(seed)
 		trainSet := NewTrainSet(set)
+		userIndices := trainSet.UserIndices()
 		testSize := int(float64(trainSet.UserCount) * testRatio)
 		for i := 0; i < repeat; i++ {
-			trainUsers, trainItems, trainRatings :=
-				make([]int, 0, trainSet.Length()-trainSet.UserCount),
-				make([]int, 0, trainSet.Length()-trainSet.UserCount),
-				make([]float64, 0, trainSet.Length()-trainSet.UserCount)
-			testUsers, testItems, testRatings :=
-				make([]int, 0, trainSet.UserCount),
-				make([]int, 0, trainSet.UserCount),
-				make([]float64, 0, trainSet.UserCount)
+			trainIndex := make([]int, 0, trainSet.Length()-trainSet.UserCount)
+			testIndex := make([]int, 0, trainSet.UserCount)
 			userPerm := rand.Perm(trainSet.UserCount)
 			userTest := userPerm[:testSize]
 			userTrain := userPerm[testSize:]
-			userRatings := trainSet.UserRatings()
 			// Add all train user's ratings to train set
-			for _, userId := range userTrain {
-				for _, ir := range userRatings[userId] {
-					trainUsers = append(trainUsers, userId)
-					trainItems = append(trainItems, ir.Id)
-					trainRatings = append(trainRatings, ir.Rating)
-				}
+			for _, innerUserId := range userTrain {
+				trainIndex = append(trainIndex, userIndices[innerUserId]...)
 			}
 			// Add test user's ratings to train set and test set
-			for _, userId := range userTest {
-				ratingPerm := rand.Perm(len(userRatings[userId]))
+			for _, innerUserId := range userTest {
+				ratingPerm := rand.Perm(len(userIndices[innerUserId]))
 				for i, index := range ratingPerm {
 					if i < n {
-						trainUsers = append(trainUsers, userId)
-						trainItems = append(trainItems, userRatings[userId][index].Id)
-						trainRatings = append(trainRatings, userRatings[userId][index].Rating)
+						trainIndex = append(trainIndex, userIndices[innerUserId][index])
 					} else {
-						testUsers = append(testUsers, userId)
-						testItems = append(testItems, userRatings[userId][index].Id)
-						testRatings = append(testRatings, userRatings[userId][index].Rating)
+						testIndex = append(testIndex, userIndices[innerUserId][index])
 					}
 				}
 			}
-			trainFolds[i] = NewTrainSet(NewRawDataSet(trainUsers, trainItems, trainRatings))
-			testFolds[i] = NewRawDataSet(testUsers, testItems, testRatings)
+			trainFolds[i] = NewTrainSet(set.SubSet(trainIndex))
+			testFolds[i] = set.SubSet(testIndex)
 		}
 		return trainFolds, testFolds
 	}
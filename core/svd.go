@@ -2,6 +2,7 @@ package core
 
 import (
 	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
 	"math"
 	"runtime"
 	"sync"
@@ -25,17 +26,23 @@ type SVD struct {
 	ItemBias   []float64   // b_i
 	GlobalBias float64     // mu
 	// Hyper parameters
-	bias       bool
-	nFactors   int
-	nEpochs    int
-	lr         float64
-	reg        float64
-	initMean   float64
-	initStdDev float64
-	optimizer  Optimizer
+	bias          bool
+	nFactors      int
+	nEpochs       int
+	partialEpochs int
+	lr            float64
+	reg           float64
+	pairReg       float64
+	initMean      float64
+	initStdDev    float64
+	optimizer     Optimizer
 	// Optimization
-	a []float64 // Pre-allocated buffer 'a'
-	b []float64 // Pre-allocated buffer 'b'
+	updater         *updater
+	globalBiasState *updaterState
+	userBiasState   *updaterState
+	itemBiasState   *updaterState
+	userFactorState *updaterState
+	itemFactorState *updaterState
 }
 
 // NewSVD creates a SVD model. Parameters:
@@ -45,9 +52,25 @@ type SVD struct {
 //	 lr 		- The learning rate of SGD. Default is 0.005.
 //	 nFactors	- The number of latent factors. Default is 100.
 //	 nEpochs	- The number of iteration of the SGD procedure. Default is 20.
+//	 partialEpochs	- The number of iteration of the optimizer in PartialFit. Default is 1.
 //	 initMean	- The mean of initial random latent factors. Default is 0.
 //	 initStdDev	- The standard deviation of initial random latent factors. Default is 0.1.
-//   optimizer  - The optimizer to optimize model parameters. Default is SGDOptimizer.
+//	 pairReg	- The regularization parameter of latent factors in pairwise updates. Default is 0.
+//   optimizer  - The optimizer to optimize model parameters: SGDOptimizer, BPROptimizer,
+//                LogisticOptimizer, WARPOptimizer, ALSOptimizer, HogwildSGDOptimizer or
+//                HogwildBPROptimizer. Default is SGDOptimizer.
+//   updater    - The per-parameter updater of SGD-style optimizers: "sgd", "adagrad",
+//                "rmsprop" or "adam". Hogwild optimizers only support "sgd". Default is "sgd".
+//   lrSchedule - The learning rate schedule: "constant", "step", "exponential" or "inverse".
+//                Default is "constant".
+//   lrDecay    - The decay rate of the learning rate schedule. Default is 0.9.
+//   lrStepSize - The number of epochs between decays of the step schedule. Default is 10.
+//   sampler    - The negative sampler of BPR optimizers and LogisticOptimizer: "uniform",
+//                "popularity", "inbatch" or "hard". Default is "uniform".
+//	 nJobs		- The number of goroutines of ALSOptimizer and Hogwild optimizers. Default is
+//				  the number of CPUs.
+//   hogwildDeterministic - Hogwild optimizers run goroutines one by one so that results are
+//                          reproducible. Default is false.
 func NewSVD(params Parameters) *SVD {
 	svd := new(SVD)
 	svd.SetParams(params)
@@ -60,8 +83,10 @@ func (svd *SVD) SetParams(params Parameters) {
 	svd.bias = svd.Params.GetBool("bias", true)
 	svd.nFactors = svd.Params.GetInt("nFactors", 100)
 	svd.nEpochs = svd.Params.GetInt("nEpochs", 20)
+	svd.partialEpochs = svd.Params.GetInt("partialEpochs", 1)
 	svd.lr = svd.Params.GetFloat64("lr", 0.005)
 	svd.reg = svd.Params.GetFloat64("reg", 0.02)
+	svd.pairReg = svd.Params.GetFloat64("pairReg", 0)
 	svd.initMean = svd.Params.GetFloat64("initMean", 0)
 	svd.initStdDev = svd.Params.GetFloat64("initStdDev", 0.1)
 	svd.optimizer = svd.Params.GetOptimizer("optimizer", SGDOptimizer)
@@ -97,13 +122,95 @@ func (svd *SVD) Fit(trainSet TrainSet) {
 	svd.ItemBias = make([]float64, trainSet.ItemCount)
 	svd.UserFactor = svd.newNormalMatrix(trainSet.UserCount, svd.nFactors, svd.initMean, svd.initStdDev)
 	svd.ItemFactor = svd.newNormalMatrix(trainSet.ItemCount, svd.nFactors, svd.initMean, svd.initStdDev)
-	// Create buffers
-	svd.a = make([]float64, svd.nFactors)
-	svd.b = make([]float64, svd.nFactors)
+	// Create updater
+	svd.updater = newUpdater(svd.Params, svd.lr)
+	svd.globalBiasState = svd.updater.newState(1)
+	svd.userBiasState = svd.updater.newState(trainSet.UserCount)
+	svd.itemBiasState = svd.updater.newState(trainSet.ItemCount)
+	svd.userFactorState = svd.updater.newState(trainSet.UserCount * svd.nFactors)
+	svd.itemFactorState = svd.updater.newState(trainSet.ItemCount * svd.nFactors)
 	// Optimize
 	svd.optimizer(svd, trainSet, svd.nEpochs)
 }
 
+// PartialFit updates a fitted SVD model with new ratings. Biases of new users and
+// new items are initialized as zeros and their factors are initialized randomly.
+// Then, model parameters are updated by the optimizer for partialEpochs epochs on
+// new ratings only, while ratings in the train set aren't revisited. Negatives of
+// pairwise and logistic optimizers are sampled against all ratings and the random
+// generator is seeded differently in each update. If the optimizer is ALSOptimizer,
+// only users and items in new ratings are solved again from all their ratings, while
+// the global bias is kept.
+func (svd *SVD) PartialFit(dataSet DataSet) {
+	delta := svd.Data.Append(dataSet)
+	// Grow parameters
+	nNewUsers := delta.UserCount - len(svd.UserFactor)
+	nNewItems := delta.ItemCount - len(svd.ItemFactor)
+	svd.UserBias = append(svd.UserBias, make([]float64, nNewUsers)...)
+	svd.ItemBias = append(svd.ItemBias, make([]float64, nNewItems)...)
+	svd.UserFactor = append(svd.UserFactor, svd.newNormalMatrix(nNewUsers, svd.nFactors, svd.initMean, svd.initStdDev)...)
+	svd.ItemFactor = append(svd.ItemFactor, svd.newNormalMatrix(nNewItems, svd.nFactors, svd.initMean, svd.initStdDev)...)
+	// Grow updater states
+	svd.userBiasState.grow(delta.UserCount)
+	svd.itemBiasState.grow(delta.ItemCount)
+	svd.userFactorState.grow(delta.UserCount * svd.nFactors)
+	svd.itemFactorState.grow(delta.ItemCount * svd.nFactors)
+	// Optimize
+	if isALSOptimizer(svd.optimizer) {
+		partialALS(svd, svd.Data, delta, svd.partialEpochs)
+	} else {
+		// Reseed optimizers from the model so that updates draw different samples
+		params := svd.Params
+		svd.Params = params.Copy()
+		svd.Params["randState"] = svd.rng.Int()
+		svd.optimizer(svd, svd.Data.withSamples(dataSet), svd.partialEpochs)
+		svd.Params = params
+	}
+}
+
+// FoldIn adds a new user into a fitted SVD model without refitting. The bias and
+// the factor of the user are solved by ridge regression on given ratings with
+// item biases and item factors fixed:
+//
+//   \min_{b_u,p_u} \sum_{i \in R_u} (r_{ui} - μ - b_i - b_u - q_i^Tp_u)^2 + λ|R_u|(b_u^2 + ||p_u||^2)
+//
+// The user is registered in the train set of the model so that later predictions
+// are personalized. Items not in the train set are ignored. If the user exists,
+// the bias and the factor are solved again from given ratings.
+func (svd *SVD) FoldIn(userId int, itemIds []int, ratings []float64) {
+	// Collect ratings of known items
+	irs := make([]IdRating, 0, len(itemIds))
+	for k, itemId := range itemIds {
+		if innerItemId := svd.Data.ConvertItemId(itemId); innerItemId != NewId {
+			irs = append(irs, IdRating{innerItemId, ratings[k]})
+		}
+	}
+	// Register the user
+	innerUserId := svd.Data.AddUser(userId)
+	for len(svd.UserFactor) < svd.Data.UserCount {
+		svd.UserBias = append(svd.UserBias, 0)
+		svd.UserFactor = append(svd.UserFactor, make([]float64, svd.nFactors))
+	}
+	// Solve the bias and the factor
+	userFactor := svd.UserFactor[innerUserId]
+	resetZeroVector(userFactor)
+	globalBias, userBiases, itemBias := svd.Biases()
+	mean := 0.0
+	if globalBias != nil {
+		mean = *globalBias
+	}
+	userBias := biasOf(userBiases, innerUserId)
+	if userBias != nil {
+		*userBias = 0
+	}
+	leastSquares(userFactor, userBias, irs, svd.ItemFactor, itemBias, mean, svd.reg)
+}
+
+// StartEpoch updates the learning rate by the schedule.
+func (svd *SVD) StartEpoch(epoch int) {
+	svd.updater.setEpoch(epoch)
+}
+
 // PointUpdate updates model parameters by point.
 func (svd *SVD) PointUpdate(upGrad float64, innerUserId, innerItemId int) {
 	if svd.bias {
@@ -111,56 +218,65 @@ func (svd *SVD) PointUpdate(upGrad float64, innerUserId, innerItemId int) {
 		itemBias := svd.ItemBias[innerItemId]
 		// Update global Bias
 		gradGlobalBias := upGrad
-		svd.GlobalBias += svd.lr * gradGlobalBias
+		svd.GlobalBias += svd.updater.update(svd.globalBiasState, 0, gradGlobalBias)
 		// Update user Bias
 		gradUserBias := upGrad + svd.reg*userBias
-		svd.UserBias[innerUserId] += svd.lr * gradUserBias
+		svd.UserBias[innerUserId] += svd.updater.update(svd.userBiasState, innerUserId, gradUserBias)
 		// Update item Bias
 		gradItemBias := upGrad + svd.reg*itemBias
-		svd.ItemBias[innerItemId] += svd.lr * gradItemBias
+		svd.ItemBias[innerItemId] += svd.updater.update(svd.itemBiasState, innerItemId, gradItemBias)
 	}
 	userFactor := svd.UserFactor[innerUserId]
 	itemFactor := svd.ItemFactor[innerItemId]
-	// Update user latent factor
-	copy(svd.a, itemFactor)
-	mulConst(upGrad, svd.a)
-	copy(svd.b, userFactor)
-	mulConst(svd.reg, svd.b)
-	floats.Sub(svd.a, svd.b)
-	mulConst(svd.lr, svd.a)
-	floats.Add(svd.UserFactor[innerUserId], svd.a)
-	// Update item latent factor
-	copy(svd.a, userFactor)
-	mulConst(upGrad, svd.a)
-	copy(svd.b, itemFactor)
-	mulConst(svd.reg, svd.b)
-	floats.Sub(svd.a, svd.b)
-	mulConst(svd.lr, svd.a)
-	floats.Add(svd.ItemFactor[innerItemId], svd.a)
-}
-
-// PairUpdate updates model parameters by pair.
+	userOffset := innerUserId * svd.nFactors
+	itemOffset := innerItemId * svd.nFactors
+	for f := range userFactor {
+		pu, qi := userFactor[f], itemFactor[f]
+		// Update user latent factor
+		userFactor[f] += svd.updater.update(svd.userFactorState, userOffset+f, upGrad*qi-svd.reg*pu)
+		// Update item latent factor
+		itemFactor[f] += svd.updater.update(svd.itemFactorState, itemOffset+f, upGrad*pu-svd.reg*qi)
+	}
+}
+
+// Factors returns latent factors of users and items.
+func (svd *SVD) Factors() ([][]float64, [][]float64) {
+	return svd.UserFactor, svd.ItemFactor
+}
+
+// Biases returns biases if bias is used.
+func (svd *SVD) Biases() (*float64, []float64, []float64) {
+	if svd.bias {
+		return &svd.GlobalBias, svd.UserBias, svd.ItemBias
+	}
+	return nil, nil, nil
+}
+
+// Reg returns the regularization parameter.
+func (svd *SVD) Reg() float64 {
+	return svd.reg
+}
+
+// PairUpdate updates model parameters by pair. Latent factors are regularized by pairReg.
 func (svd *SVD) PairUpdate(upGrad float64, innerUserId, positiveItemId, negativeItemId int) {
 	userFactor := svd.UserFactor[innerUserId]
 	positiveItemFactor := svd.ItemFactor[positiveItemId]
 	negativeItemFactor := svd.ItemFactor[negativeItemId]
-	// Update positive item latent factor: +w_u
-	copy(svd.a, userFactor)
-	mulConst(upGrad, svd.a)
-	mulConst(svd.lr, svd.a)
-	floats.Add(svd.ItemFactor[positiveItemId], svd.a)
-	// Update negative item latent factor: -w_u
-	copy(svd.a, userFactor)
-	neg(svd.a)
-	mulConst(upGrad, svd.a)
-	mulConst(svd.lr, svd.a)
-	floats.Add(svd.ItemFactor[negativeItemId], svd.a)
-	// Update user latent factor: h_i-h_j
-	copy(svd.a, positiveItemFactor)
-	floats.Sub(svd.a, negativeItemFactor)
-	mulConst(upGrad, svd.a)
-	mulConst(svd.lr, svd.a)
-	floats.Add(svd.UserFactor[innerUserId], svd.a)
+	userOffset := innerUserId * svd.nFactors
+	positiveOffset := positiveItemId * svd.nFactors
+	negativeOffset := negativeItemId * svd.nFactors
+	for f := range userFactor {
+		pu := userFactor[f]
+		// Update positive item latent factor: +w_u
+		positiveItemFactor[f] += svd.updater.update(svd.itemFactorState, positiveOffset+f,
+			upGrad*pu-svd.pairReg*positiveItemFactor[f])
+		// Update negative item latent factor: -w_u
+		negativeItemFactor[f] += svd.updater.update(svd.itemFactorState, negativeOffset+f,
+			-upGrad*pu-svd.pairReg*negativeItemFactor[f])
+		// Update user latent factor: h_i-h_j
+		userFactor[f] += svd.updater.update(svd.userFactorState, userOffset+f,
+			upGrad*(positiveItemFactor[f]-negativeItemFactor[f])-svd.pairReg*pu)
+	}
 }
 
 /* NMF */
@@ -297,6 +413,7 @@ type SVDpp struct {
 //	 lr 		- The learning rate of SGD. Default is 0.007.
 //	 nFactors	- The number of latent factors. Default is 20.
 //	 nEpochs	- The number of iteration of the SGD procedure. Default is 20.
+//	 partialEpochs	- The number of iteration of the SGD procedure in PartialFit. Default is 1.
 //	 initMean	- The mean of initial random latent factors. Default is 0.
 //	 initStdDev	- The standard deviation of initial random latent factors. Default is 0.1.
 //	 nJobs		- The number of goroutines to update implicit factors. Default is the number of CPUs.
@@ -357,12 +474,8 @@ func (svd *SVDpp) Fit(trainSet TrainSet) {
 	svd.Base.Fit(trainSet)
 	// Setup parameters
 	nFactors := svd.Params.GetInt("nFactors", 20)
-	nEpochs := svd.Params.GetInt("nEpochs", 20)
-	lr := svd.Params.GetFloat64("lr", 0.007)
-	reg := svd.Params.GetFloat64("reg", 0.02)
 	initMean := svd.Params.GetFloat64("initMean", 0)
 	initStdDev := svd.Params.GetFloat64("initStdDev", 0.1)
-	nJobs := svd.Params.GetInt("nJobs", runtime.NumCPU())
 	// Initialize parameters
 	svd.UserBias = make([]float64, trainSet.UserCount)
 	svd.ItemBias = make([]float64, trainSet.ItemCount)
@@ -372,6 +485,39 @@ func (svd *SVDpp) Fit(trainSet TrainSet) {
 	//svd.cacheFactor = make(map[int][]float64)
 	// Build user rating set
 	svd.UserRatings = trainSet.UserRatings()
+	svd.sgd(trainSet, svd.Params.GetInt("nEpochs", 20), true)
+}
+
+// PartialFit updates a fitted SVD++ model with new ratings. Biases of new users and
+// new items are initialized as zeros and their factors are initialized randomly.
+// Then, model parameters except the global bias are updated by SGD for partialEpochs
+// epochs on new ratings only, while implicit feedback of users comes from all ratings.
+func (svd *SVDpp) PartialFit(dataSet DataSet) {
+	nFactors := svd.Params.GetInt("nFactors", 20)
+	initMean := svd.Params.GetFloat64("initMean", 0)
+	initStdDev := svd.Params.GetFloat64("initStdDev", 0.1)
+	delta := svd.Data.Append(dataSet)
+	// Grow parameters
+	nNewUsers := delta.UserCount - len(svd.UserFactor)
+	nNewItems := delta.ItemCount - len(svd.ItemFactor)
+	svd.UserBias = append(svd.UserBias, make([]float64, nNewUsers)...)
+	svd.ItemBias = append(svd.ItemBias, make([]float64, nNewItems)...)
+	svd.UserFactor = append(svd.UserFactor, svd.newNormalMatrix(nNewUsers, nFactors, initMean, initStdDev)...)
+	svd.ItemFactor = append(svd.ItemFactor, svd.newNormalMatrix(nNewItems, nFactors, initMean, initStdDev)...)
+	svd.ImplFactor = append(svd.ImplFactor, svd.newNormalMatrix(nNewItems, nFactors, initMean, initStdDev)...)
+	// Rebuild user rating set
+	svd.UserRatings = svd.Data.UserRatings()
+	svd.sgd(delta, svd.Params.GetInt("partialEpochs", 1), false)
+}
+
+// Update model parameters by SGD on a train set for nEpochs epochs. The global bias
+// is fixed if updateGlobalBias is false.
+func (svd *SVDpp) sgd(trainSet TrainSet, nEpochs int, updateGlobalBias bool) {
+	// Setup parameters
+	nFactors := svd.Params.GetInt("nFactors", 20)
+	lr := svd.Params.GetFloat64("lr", 0.007)
+	reg := svd.Params.GetFloat64("reg", 0.02)
+	nJobs := svd.Params.GetInt("nJobs", runtime.NumCPU())
 	// Create buffers
 	a := make([]float64, nFactors)
 	b := make([]float64, nFactors)
@@ -389,8 +535,10 @@ func (svd *SVDpp) Fit(trainSet TrainSet) {
 			pred, emImpFactor := svd.internalPredict(userId, itemId)
 			diff := pred - rating
 			// Update global Bias
-			gradGlobalBias := diff
-			svd.GlobalBias -= lr * gradGlobalBias
+			if updateGlobalBias {
+				gradGlobalBias := diff
+				svd.GlobalBias -= lr * gradGlobalBias
+			}
 			// Update user Bias
 			gradUserBias := diff + reg*userBias
 			svd.UserBias[innerUserId] -= lr * gradUserBias
@@ -417,30 +565,385 @@ func (svd *SVDpp) Fit(trainSet TrainSet) {
 			mulConst(lr, a)
 			floats.Sub(svd.ItemFactor[innerItemId], a)
 			// Update implicit latent factor
-			nRating := len(svd.UserRatings[innerUserId])
-			var wg sync.WaitGroup
-			wg.Add(nJobs)
-			for j := 0; j < nJobs; j++ {
-				go func(jobId int) {
-					low := nRating * jobId / nJobs
-					high := nRating * (jobId + 1) / nJobs
-					a := make([]float64, nFactors)
-					b := make([]float64, nFactors)
-					for i := low; i < high; i++ {
-						implFactor := svd.ImplFactor[svd.UserRatings[innerUserId][i].Id]
-						copy(a, itemFactor)
-						mulConst(diff, a)
-						divConst(math.Sqrt(float64(len(svd.UserRatings[innerUserId]))), a)
-						copy(b, implFactor)
-						mulConst(reg, b)
-						floats.Add(a, b)
-						mulConst(lr, a)
-						floats.Sub(svd.ImplFactor[svd.UserRatings[innerUserId][i].Id], a)
-					}
-					wg.Done()
-				}(j)
+			svd.updateImplFactors(innerUserId, itemFactor, diff, lr, reg, nJobs)
+		}
+	}
+}
+
+// Update implicit latent factors y_j of items rated by a user.
+func (svd *SVDpp) updateImplFactors(innerUserId int, itemFactor []float64, diff, lr, reg float64, nJobs int) {
+	nFactors := len(itemFactor)
+	nRating := len(svd.UserRatings[innerUserId])
+	var wg sync.WaitGroup
+	wg.Add(nJobs)
+	for j := 0; j < nJobs; j++ {
+		go func(jobId int) {
+			low := nRating * jobId / nJobs
+			high := nRating * (jobId + 1) / nJobs
+			a := make([]float64, nFactors)
+			b := make([]float64, nFactors)
+			for i := low; i < high; i++ {
+				implFactor := svd.ImplFactor[svd.UserRatings[innerUserId][i].Id]
+				copy(a, itemFactor)
+				mulConst(diff, a)
+				divConst(math.Sqrt(float64(len(svd.UserRatings[innerUserId]))), a)
+				copy(b, implFactor)
+				mulConst(reg, b)
+				floats.Add(a, b)
+				mulConst(lr, a)
+				floats.Sub(svd.ImplFactor[svd.UserRatings[innerUserId][i].Id], a)
 			}
-			wg.Wait()
+			wg.Done()
+		}(j)
+	}
+	wg.Wait()
+}
+
+/* timeSVD++ */
+
+// The number of seconds in a day.
+const secondsPerDay = 24 * 60 * 60
+
+// TimeSVDpp: SVD++ with temporal dynamics[13]. Ratings are indexed by days
+// from timestamps. The prediction \hat{r}_{ui}(t) at day t is set as:
+//
+//   \hat{r}_{ui}(t) = μ + b_i + b_{i,Bin(t)} + b_u + α_u dev_u(t) + b_{u,t}
+//                   + q_i^T\left(p_u + α^p_u dev_u(t) + |I_u|^{-\frac{1}{2}} \sum_{j \in I_u}y_j\right)
+//
+// where dev_u(t) = sign(t - t_u)|t - t_u|^β is the drift from the mean rating
+// day t_u of user u, b_{i,Bin(t)} is the item bias in the time bin of day t and
+// b_{u,t} is the user bias at day t. Predict() estimates the rating at the last
+// day of the user in the train set while PredictWithTime() estimates the rating
+// at a given timestamp, which is used by RMSE and MAE. Timestamps should be
+// loaded in the data set. TimeSVDpp isn't an IncrementalModel since time-dependent
+// parameters can't be updated incrementally.
+type TimeSVDpp struct {
+	Base
+	UserRatings     [][]IdRating      // I_u
+	UserFactor      [][]float64       // p_u
+	ItemFactor      [][]float64       // q_i
+	ImplFactor      [][]float64       // y_i
+	UserBias        []float64         // b_u
+	ItemBias        []float64         // b_i
+	GlobalBias      float64           // mu
+	ItemBinBias     [][]float64       // b_{i,Bin(t)}
+	UserDayBias     []map[int]float64 // b_{u,t}
+	UserAlpha       []float64         // α_u
+	UserFactorAlpha [][]float64       // α^p_u
+	UserMeanDay     []float64         // t_u
+	UserLastDay     []int
+	MinDay          int
+	MaxDay          int
+	// Hyper parameters
+	nBins int
+	beta  float64
+}
+
+// NewTimeSVDpp creates a timeSVD++ model. Parameters:
+//	 reg 		- The regularization parameter of the cost function that is
+// 				  optimized. Default is 0.02.
+//	 lr 		- The learning rate of SGD. Default is 0.007.
+//	 regTime 	- The regularization parameter of time-dependent biases. Default is 0.005.
+//	 lrTime 	- The learning rate of time-dependent biases. Default is 0.005.
+//	 regAlpha 	- The regularization parameter of drift coefficients. Default is 50.
+//	 lrAlpha 	- The learning rate of drift coefficients. Default is 1e-5.
+//	 nBins		- The number of time bins of item biases. Default is 30.
+//	 beta		- The exponent of the drift function. Default is 0.4.
+//	 nFactors	- The number of latent factors. Default is 20.
+//	 nEpochs	- The number of iteration of the SGD procedure. Default is 20.
+//	 initMean	- The mean of initial random latent factors. Default is 0.
+//	 initStdDev	- The standard deviation of initial random latent factors. Default is 0.1.
+func NewTimeSVDpp(params Parameters) *TimeSVDpp {
+	svd := new(TimeSVDpp)
+	svd.Params = params
+	return svd
+}
+
+// Compute |I_u|^{-\frac{1}{2}} \sum_{j \in I_u}y_j of a user.
+func (svd *TimeSVDpp) ensembleImplFactors(innerUserId int) []float64 {
+	emImpFactor := make([]float64, len(svd.ImplFactor[0]))
+	for _, ir := range svd.UserRatings[innerUserId] {
+		floats.Add(emImpFactor, svd.ImplFactor[ir.Id])
+	}
+	divConst(math.Sqrt(float64(len(svd.UserRatings[innerUserId]))), emImpFactor)
+	return emImpFactor
+}
+
+// The time bin of a day.
+func (svd *TimeSVDpp) bin(day int) int {
+	bin := (day - svd.MinDay) * svd.nBins / (svd.MaxDay - svd.MinDay + 1)
+	if bin < 0 {
+		return 0
+	} else if bin >= svd.nBins {
+		return svd.nBins - 1
+	}
+	return bin
+}
+
+// The drift of a user at a day: dev_u(t) = sign(t - t_u)|t - t_u|^β
+func (svd *TimeSVDpp) dev(innerUserId int, day int) float64 {
+	diff := float64(day) - svd.UserMeanDay[innerUserId]
+	if diff < 0 {
+		return -math.Pow(-diff, svd.beta)
+	}
+	return math.Pow(diff, svd.beta)
+}
+
+// Predict the rating at a day given |I_u|^{-\frac{1}{2}} \sum_{j \in I_u}y_j of the user.
+func (svd *TimeSVDpp) internalPredict(innerUserId, innerItemId int, day int, emImpFactor []float64) float64 {
+	ret := svd.GlobalBias
+	// + b_u + α_u dev_u(t) + b_{u,t}
+	if innerUserId != NewId {
+		ret += svd.UserBias[innerUserId] + svd.UserAlpha[innerUserId]*svd.dev(innerUserId, day) +
+			svd.UserDayBias[innerUserId][day]
+	}
+	// + b_i + b_{i,Bin(t)}
+	if innerItemId != NewId {
+		ret += svd.ItemBias[innerItemId] + svd.ItemBinBias[innerItemId][svd.bin(day)]
+	}
+	// + q_i^T\left(p_u + α^p_u dev_u(t) + |I_u|^{-\frac{1}{2}} \sum_{j \in I_u}y_j\right)
+	if innerItemId != NewId && innerUserId != NewId {
+		temp := make([]float64, len(emImpFactor))
+		floats.AddScaled(temp, svd.dev(innerUserId, day), svd.UserFactorAlpha[innerUserId])
+		floats.Add(temp, svd.UserFactor[innerUserId])
+		floats.Add(temp, emImpFactor)
+		ret += floats.Dot(temp, svd.ItemFactor[innerItemId])
+	}
+	return ret
+}
+
+// Predict by a timeSVD++ model at the last day of the user.
+func (svd *TimeSVDpp) Predict(userId int, itemId int) float64 {
+	innerUserId := svd.Data.ConvertUserId(userId)
+	innerItemId := svd.Data.ConvertItemId(itemId)
+	day := svd.MaxDay
+	var emImpFactor []float64
+	if innerUserId != NewId {
+		day = svd.UserLastDay[innerUserId]
+		emImpFactor = svd.ensembleImplFactors(innerUserId)
+	}
+	return svd.internalPredict(innerUserId, innerItemId, day, emImpFactor)
+}
+
+// PredictWithTime predicts the rating at a timestamp by a timeSVD++ model.
+func (svd *TimeSVDpp) PredictWithTime(userId int, itemId int, timestamp int64) float64 {
+	innerUserId := svd.Data.ConvertUserId(userId)
+	innerItemId := svd.Data.ConvertItemId(itemId)
+	var emImpFactor []float64
+	if innerUserId != NewId {
+		emImpFactor = svd.ensembleImplFactors(innerUserId)
+	}
+	return svd.internalPredict(innerUserId, innerItemId, int(timestamp/secondsPerDay), emImpFactor)
+}
+
+// Fit a timeSVD++ model.
+func (svd *TimeSVDpp) Fit(trainSet TrainSet) {
+	svd.Base.Fit(trainSet)
+	// Setup parameters
+	nFactors := svd.Params.GetInt("nFactors", 20)
+	nEpochs := svd.Params.GetInt("nEpochs", 20)
+	lr := svd.Params.GetFloat64("lr", 0.007)
+	reg := svd.Params.GetFloat64("reg", 0.02)
+	lrTime := svd.Params.GetFloat64("lrTime", 0.005)
+	regTime := svd.Params.GetFloat64("regTime", 0.005)
+	lrAlpha := svd.Params.GetFloat64("lrAlpha", 1e-5)
+	regAlpha := svd.Params.GetFloat64("regAlpha", 50)
+	initMean := svd.Params.GetFloat64("initMean", 0)
+	initStdDev := svd.Params.GetFloat64("initStdDev", 0.1)
+	svd.nBins = svd.Params.GetInt("nBins", 30)
+	svd.beta = svd.Params.GetFloat64("beta", 0.4)
+	// Initialize parameters
+	svd.GlobalBias = 0
+	svd.UserBias = make([]float64, trainSet.UserCount)
+	svd.ItemBias = make([]float64, trainSet.ItemCount)
+	svd.UserFactor = svd.newNormalMatrix(trainSet.UserCount, nFactors, initMean, initStdDev)
+	svd.ItemFactor = svd.newNormalMatrix(trainSet.ItemCount, nFactors, initMean, initStdDev)
+	svd.ImplFactor = svd.newNormalMatrix(trainSet.ItemCount, nFactors, initMean, initStdDev)
+	svd.ItemBinBias = newZeroMatrix(trainSet.ItemCount, svd.nBins)
+	svd.UserDayBias = make([]map[int]float64, trainSet.UserCount)
+	for u := range svd.UserDayBias {
+		svd.UserDayBias[u] = make(map[int]float64)
+	}
+	svd.UserAlpha = make([]float64, trainSet.UserCount)
+	svd.UserFactorAlpha = newZeroMatrix(trainSet.UserCount, nFactors)
+	svd.UserRatings = trainSet.UserRatings()
+	// Compute days and group ratings by users
+	days := make([]int, trainSet.Length())
+	userIndices := make([][]int, trainSet.UserCount)
+	count := make([]float64, trainSet.UserCount)
+	svd.UserMeanDay = make([]float64, trainSet.UserCount)
+	svd.UserLastDay = make([]int, trainSet.UserCount)
+	svd.MinDay, svd.MaxDay = math.MaxInt32, math.MinInt32
+	for i := range days {
+		userId, _, _ := trainSet.Index(i)
+		innerUserId := trainSet.ConvertUserId(userId)
+		days[i] = int(trainSet.Timestamp(i) / secondsPerDay)
+		userIndices[innerUserId] = append(userIndices[innerUserId], i)
+		if count[innerUserId] == 0 || days[i] > svd.UserLastDay[innerUserId] {
+			svd.UserLastDay[innerUserId] = days[i]
 		}
+		svd.UserMeanDay[innerUserId] += float64(days[i])
+		count[innerUserId]++
+		if days[i] < svd.MinDay {
+			svd.MinDay = days[i]
+		}
+		if days[i] > svd.MaxDay {
+			svd.MaxDay = days[i]
+		}
+	}
+	floats.Div(svd.UserMeanDay, count)
+	// Create buffers
+	a := make([]float64, nFactors)
+	gradImplFactor := make([]float64, nFactors)
+	// Stochastic Gradient Descent over users. The implicit feedback of a user is computed
+	// once and implicit factors are updated after all ratings of the user are visited.
+	for epoch := 0; epoch < nEpochs; epoch++ {
+		for _, innerUserId := range svd.rng.Perm(trainSet.UserCount) {
+			if len(userIndices[innerUserId]) == 0 {
+				continue
+			}
+			userFactor := svd.UserFactor[innerUserId]
+			userFactorAlpha := svd.UserFactorAlpha[innerUserId]
+			emImpFactor := svd.ensembleImplFactors(innerUserId)
+			resetZeroVector(gradImplFactor)
+			for _, i := range userIndices[innerUserId] {
+				_, itemId, rating := trainSet.Index(i)
+				innerItemId := trainSet.ConvertItemId(itemId)
+				day, bin, dev := days[i], svd.bin(days[i]), svd.dev(innerUserId, days[i])
+				itemFactor := svd.ItemFactor[innerItemId]
+				// Compute error
+				diff := svd.internalPredict(innerUserId, innerItemId, day, emImpFactor) - rating
+				// Update global Bias
+				svd.GlobalBias -= lr * diff
+				// Update user Bias
+				svd.UserBias[innerUserId] -= lr * (diff + reg*svd.UserBias[innerUserId])
+				svd.UserAlpha[innerUserId] -= lrAlpha * (diff*dev + regAlpha*svd.UserAlpha[innerUserId])
+				svd.UserDayBias[innerUserId][day] -= lrTime * (diff + regTime*svd.UserDayBias[innerUserId][day])
+				// Update item Bias
+				svd.ItemBias[innerItemId] -= lr * (diff + reg*svd.ItemBias[innerItemId])
+				svd.ItemBinBias[innerItemId][bin] -= lrTime * (diff + regTime*svd.ItemBinBias[innerItemId][bin])
+				// Compute p_u + α^p_u dev_u(t) + |I_u|^{-\frac{1}{2}} \sum_{j \in I_u}y_j
+				copy(a, userFactor)
+				floats.AddScaled(a, dev, userFactorAlpha)
+				floats.Add(a, emImpFactor)
+				// Accumulate the gradient of implicit factors
+				floats.AddScaled(gradImplFactor, diff, itemFactor)
+				// Update user latent factor
+				for f := range userFactor {
+					grad := diff * itemFactor[f]
+					userFactor[f] -= lr * (grad + reg*userFactor[f])
+					userFactorAlpha[f] -= lrAlpha * (grad*dev + regAlpha*userFactorAlpha[f])
+				}
+				// Update item latent factor
+				for f := range itemFactor {
+					itemFactor[f] -= lr * (diff*a[f] + reg*itemFactor[f])
+				}
+			}
+			// Update implicit latent factors
+			norm := math.Sqrt(float64(len(svd.UserRatings[innerUserId])))
+			for _, ir := range svd.UserRatings[innerUserId] {
+				implFactor := svd.ImplFactor[ir.Id]
+				for f := range implFactor {
+					implFactor[f] -= lr * (gradImplFactor[f]/norm + reg*implFactor[f])
+				}
+			}
+		}
+	}
+}
+
+/* WRMF */
+
+// WRMF: Weighted Regularized Matrix Factorization for implicit feedback[10].
+// Each rating r_{ui} is treated as a positive preference p_{ui} = 1 with
+// confidence c_{ui} = 1 + α r_{ui}, while unobserved user-item pairs are
+// negative preferences with confidence 1. The cost function
+//
+//   \sum_{u,i} c_{ui}(p_{ui} - x_u^Ty_i)^2 + λ\left(\sum_u||x_u||^2 + \sum_i||y_i||^2\right)
+//
+// is minimized by alternating least squares over users and items.
+type WRMF struct {
+	Base
+	UserFactor [][]float64 // x_u
+	ItemFactor [][]float64 // y_i
+}
+
+// NewWRMF creates a WRMF model. Parameters:
+//	 reg 		- The regularization parameter of the cost function that is
+// 				  optimized. Default is 0.1.
+//	 alpha		- The rate of confidence increase. Default is 1.
+//	 nFactors	- The number of latent factors. Default is 10.
+//	 nEpochs	- The number of iteration of the ALS procedure. Default is 10.
+//	 initMean	- The mean of initial random latent factors. Default is 0.
+//	 initStdDev	- The standard deviation of initial random latent factors. Default is 0.1.
+//	 nJobs		- The number of goroutines to solve least squares. Default is the number of CPUs.
+func NewWRMF(params Parameters) *WRMF {
+	mf := new(WRMF)
+	mf.Params = params
+	return mf
+}
+
+// Predict by a WRMF model.
+func (mf *WRMF) Predict(userId, itemId int) float64 {
+	innerUserId := mf.Data.ConvertUserId(userId)
+	innerItemId := mf.Data.ConvertItemId(itemId)
+	if innerItemId != NewId && innerUserId != NewId {
+		return floats.Dot(mf.UserFactor[innerUserId], mf.ItemFactor[innerItemId])
+	}
+	return 0
+}
+
+// Fit a WRMF model.
+func (mf *WRMF) Fit(trainSet TrainSet) {
+	mf.Base.Fit(trainSet)
+	// Setup parameters
+	nFactors := mf.Params.GetInt("nFactors", 10)
+	nEpochs := mf.Params.GetInt("nEpochs", 10)
+	reg := mf.Params.GetFloat64("reg", 0.1)
+	alpha := mf.Params.GetFloat64("alpha", 1)
+	initMean := mf.Params.GetFloat64("initMean", 0)
+	initStdDev := mf.Params.GetFloat64("initStdDev", 0.1)
+	nJobs := mf.Params.GetInt("nJobs", runtime.NumCPU())
+	// Initialize parameters
+	mf.UserFactor = mf.newNormalMatrix(trainSet.UserCount, nFactors, initMean, initStdDev)
+	mf.ItemFactor = mf.newNormalMatrix(trainSet.ItemCount, nFactors, initMean, initStdDev)
+	userRatings := trainSet.UserRatings()
+	itemRatings := trainSet.ItemRatings()
+	// Alternating least squares
+	for epoch := 0; epoch < nEpochs; epoch++ {
+		// Recompute all user factors
+		weightedLeastSquares(mf.UserFactor, mf.ItemFactor, userRatings, reg, alpha, nJobs)
+		// Recompute all item factors
+		weightedLeastSquares(mf.ItemFactor, mf.UserFactor, itemRatings, reg, alpha, nJobs)
 	}
 }
+
+// Solve x_u = (Y^TC^uY + λI)^{-1}Y^TC^up(u) for each user (item) u.
+func weightedLeastSquares(dst, factors [][]float64, idRatings [][]IdRating, reg, alpha float64, nJobs int) {
+	nFactors := len(factors[0])
+	// Y^TY
+	y := mat.NewDense(len(factors), nFactors, concatenateFloat(factors))
+	yty := mat.NewSymDense(nFactors, nil)
+	yty.SymOuterK(1, y.T())
+	parallel(len(dst), nJobs, func(begin, end int) {
+		a := mat.NewSymDense(nFactors, nil)
+		var chol mat.Cholesky
+		for u := begin; u < end; u++ {
+			// Y^TC^uY + λI = Y^TY + Y^T(C^u - I)Y + λI
+			a.CopySym(yty)
+			b := mat.NewVecDense(nFactors, nil)
+			for _, ir := range idRatings[u] {
+				confidence := 1 + alpha*ir.Rating
+				factor := mat.NewVecDense(nFactors, factors[ir.Id])
+				a.SymRankOne(a, confidence-1, factor)
+				b.AddScaledVec(b, confidence, factor)
+			}
+			for f := 0; f < nFactors; f++ {
+				a.SetSym(f, f, a.At(f, f)+reg)
+			}
+			// Solve linear equations
+			if chol.Factorize(a) {
+				chol.SolveVec(mat.NewVecDense(nFactors, dst[u]), b)
+			}
+		}
+	})
+}
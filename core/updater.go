@@ -0,0 +1,151 @@
+package core
+
+import "math"
+
+// Updater
+const (
+	updaterSGD     = "sgd"
+	updaterAdaGrad = "adagrad"
+	updaterRMSProp = "rmsprop"
+	updaterAdam    = "adam"
+)
+
+// Learning rate schedule
+const (
+	scheduleConstant    = "constant"
+	scheduleStep        = "step"
+	scheduleExponential = "exponential"
+	scheduleInverse     = "inverse"
+)
+
+// updater computes per-parameter updates for SGD-style models. The learning
+// rate of each epoch is given by a schedule:
+//
+//   constant:    η_t = η
+//   step:        η_t = η γ^{\lfloor t/s \rfloor}
+//   exponential: η_t = η γ^t
+//   inverse:     η_t = η / (1 + γt)
+//
+// Given a gradient g of a parameter, the update is:
+//
+//   sgd:     η_t g
+//   adagrad: η_t g / (\sqrt{v} + ε), where v = v + g^2
+//   rmsprop: η_t g / (\sqrt{v} + ε), where v = ρv + (1-ρ)g^2
+//   adam:    η_t \hat{m} / (\sqrt{\hat{v}} + ε), where m = β_1m + (1-β_1)g,
+//            v = β_2v + (1-β_2)g^2, \hat{m} = m/(1-β_1^k), \hat{v} = v/(1-β_2^k)
+//            and k is the number of updates of the parameter. β_1^k and β_2^k
+//            are kept as running products.
+//
+// The state of a group of parameters is stored in an updaterState.
+type updater struct {
+	method   string
+	lr       float64 // η_t
+	baseLR   float64 // η
+	schedule string
+	decay    float64 // γ
+	stepSize int     // s
+	rho      float64
+	beta1    float64
+	beta2    float64
+	epsilon  float64
+}
+
+// updaterState is the state of a group of parameters.
+type updaterState struct {
+	m      []float64 // The first moments
+	v      []float64 // The second moments
+	beta1K []float64 // β_1^k
+	beta2K []float64 // β_2^k
+}
+
+// newUpdater creates an updater. Parameters:
+//   updater    - The per-parameter updater: "sgd", "adagrad", "rmsprop" or "adam". Default is "sgd".
+//   lrSchedule - The learning rate schedule: "constant", "step", "exponential" or "inverse".
+//                Default is "constant".
+//   lrDecay    - The decay rate γ of the learning rate schedule. Default is 0.9.
+//   lrStepSize - The number of epochs between decays of the step schedule. Default is 10.
+//   rho        - The decay rate of RMSProp. Default is 0.9.
+//   beta1      - The decay rate of the first moments in Adam. Default is 0.9.
+//   beta2      - The decay rate of the second moments in Adam. Default is 0.999.
+//   epsilon    - The smoothing term of adaptive updaters. Default is 1e-8.
+func newUpdater(params Parameters, lr float64) *updater {
+	u := new(updater)
+	u.method = params.GetString("updater", updaterSGD)
+	u.baseLR = lr
+	u.lr = lr
+	u.schedule = params.GetString("lrSchedule", scheduleConstant)
+	u.decay = params.GetFloat64("lrDecay", 0.9)
+	u.stepSize = params.GetInt("lrStepSize", 10)
+	u.rho = params.GetFloat64("rho", 0.9)
+	u.beta1 = params.GetFloat64("beta1", 0.9)
+	u.beta2 = params.GetFloat64("beta2", 0.999)
+	u.epsilon = params.GetFloat64("epsilon", 1e-8)
+	return u
+}
+
+// setEpoch updates the learning rate by the schedule.
+func (u *updater) setEpoch(epoch int) {
+	switch u.schedule {
+	case scheduleStep:
+		u.lr = u.baseLR * math.Pow(u.decay, float64(epoch/u.stepSize))
+	case scheduleExponential:
+		u.lr = u.baseLR * math.Pow(u.decay, float64(epoch))
+	case scheduleInverse:
+		u.lr = u.baseLR / (1 + u.decay*float64(epoch))
+	default:
+		u.lr = u.baseLR
+	}
+}
+
+// newState creates the state of a group of parameters.
+func (u *updater) newState(size int) *updaterState {
+	state := new(updaterState)
+	switch u.method {
+	case updaterAdaGrad, updaterRMSProp:
+		state.v = make([]float64, size)
+	case updaterAdam:
+		state.m = make([]float64, size)
+		state.v = make([]float64, size)
+		state.beta1K = newOneVector(size)
+		state.beta2K = newOneVector(size)
+	}
+	return state
+}
+
+// grow appends initial states until a group of parameters has the given size.
+func (state *updaterState) grow(size int) {
+	if state.m != nil {
+		state.m = append(state.m, make([]float64, size-len(state.m))...)
+	}
+	if state.v != nil {
+		state.v = append(state.v, make([]float64, size-len(state.v))...)
+	}
+	if state.beta1K != nil {
+		state.beta1K = append(state.beta1K, newOneVector(size-len(state.beta1K))...)
+	}
+	if state.beta2K != nil {
+		state.beta2K = append(state.beta2K, newOneVector(size-len(state.beta2K))...)
+	}
+}
+
+// update returns the update of the i-th parameter in a group given its gradient.
+func (u *updater) update(state *updaterState, i int, grad float64) float64 {
+	switch u.method {
+	case updaterAdaGrad:
+		state.v[i] += grad * grad
+		return u.lr * grad / (math.Sqrt(state.v[i]) + u.epsilon)
+	case updaterRMSProp:
+		state.v[i] = u.rho*state.v[i] + (1-u.rho)*grad*grad
+		return u.lr * grad / (math.Sqrt(state.v[i]) + u.epsilon)
+	case updaterAdam:
+		state.beta1K[i] *= u.beta1
+		state.beta2K[i] *= u.beta2
+		state.m[i] = u.beta1*state.m[i] + (1-u.beta1)*grad
+		state.v[i] = u.beta2*state.v[i] + (1-u.beta2)*grad*grad
+		m := state.m[i] / (1 - state.beta1K[i])
+		v := state.v[i] / (1 - state.beta2K[i])
+		return u.lr * m / (math.Sqrt(v) + u.epsilon)
+	default:
+		return u.lr * grad
+	}
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+func TestUpdater_Schedule(t *testing.T) {
+	lrs := map[string]float64{
+		scheduleConstant:    0.1,
+		scheduleStep:        0.1 * 0.5,
+		scheduleExponential: 0.1 * math.Pow(0.5, 12),
+		scheduleInverse:     0.1 / (1 + 0.5*12),
+	}
+	for schedule, expect := range lrs {
+		u := newUpdater(Parameters{"lrSchedule": schedule, "lrDecay": 0.5}, 0.1)
+		u.setEpoch(12)
+		if math.Abs(u.lr-expect) > 1e-12 {
+			t.Fatalf("%s: learning rate %v != %v", schedule, u.lr, expect)
+		}
+	}
+}
+
+func TestUpdater_Update(t *testing.T) {
+	// The first update of AdaGrad, RMSProp and Adam is about ±lr
+	// if the smoothing term is ignored.
+	expects := map[string]float64{
+		updaterSGD:     0.1 * 2,
+		updaterAdaGrad: 0.1,
+		updaterRMSProp: 0.1 / math.Sqrt(0.1),
+		updaterAdam:    0.1,
+	}
+	for method, expect := range expects {
+		u := newUpdater(Parameters{"updater": method, "epsilon": 0.0}, 0.1)
+		state := u.newState(2)
+		if update := u.update(state, 1, 2); math.Abs(update-expect) > 1e-12 {
+			t.Fatalf("%s: update %v != %v", method, update, expect)
+		}
+	}
+	// The second update of AdaGrad is scaled by the accumulated square gradient
+	u := newUpdater(Parameters{"updater": updaterAdaGrad, "epsilon": 0.0}, 0.1)
+	state := u.newState(1)
+	u.update(state, 0, 3)
+	if update := u.update(state, 0, 4); math.Abs(update-0.1*4/5) > 1e-12 {
+		t.Fatalf("adagrad: update %v != %v", update, 0.1*4/5)
+	}
+}
@@ -52,6 +52,13 @@ func max(a []int) int {
 	return maximum
 }
 
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 func abs(dst []float64) {
 	for i := 0; i < len(dst); i++ {
 		dst[i] = math.Abs(dst[i])
@@ -84,13 +91,34 @@ func resetZeroVector(a []float64) {
 	}
 }
 
+func newRangeVector(size int) []int {
+	ret := make([]int, size)
+	for i := range ret {
+		ret[i] = i
+	}
+	return ret
+}
+
+func newOneVector(size int) []float64 {
+	ret := make([]float64, size)
+	for i := range ret {
+		ret[i] = 1
+	}
+	return ret
+}
+
+func newNanVector(size int) []float64 {
+	ret := make([]float64, size)
+	for i := range ret {
+		ret[i] = math.NaN()
+	}
+	return ret
+}
+
 func newNanMatrix(row, col int) [][]float64 {
 	ret := make([][]float64, row)
 	for i := range ret {
-		ret[i] = make([]float64, col)
-		for j := range ret[i] {
-			ret[i][j] = math.NaN()
-		}
+		ret[i] = newNanVector(col)
 	}
 	return ret
 }
@@ -144,3 +172,17 @@ func parallelMean(nTask int, nJob int, worker func(begin, end int) float64) floa
 	wg.Wait()
 	return stat.Mean(results, weights)
 }
+
+func concatenateFloat(m [][]float64) []float64 {
+	// Sum lengths
+	total := 0
+	for _, row := range m {
+		total += len(row)
+	}
+	// concatenate
+	ret := make([]float64, 0, total)
+	for _, row := range m {
+		ret = append(ret, row...)
+	}
+	return ret
+}
@@ -21,6 +21,11 @@ func benchmark(dataSet string) {
 	// Cross validation
 	estimators := []Model{
 		{"SVD", "#SVD", core.NewSVD(nil)},
+		{"WRMF[10]", "#WRMF", core.NewWRMF(nil)},
+		{"EASE[11]", "#EASE", core.NewEASE(nil)},
+		{"SLIM[12]", "#SLIM", core.NewSLIM(nil)},
+		{"ItemPop", "#ItemPop", core.NewItemPop(nil)},
+		{"TrendingPop", "#TrendingPop", core.NewTrendingPop(nil)},
 		{"Random", "#Random", core.NewRandom(nil)},
 	}
 	set := core.LoadDataFromBuiltIn(dataSet)
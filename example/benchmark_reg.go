@@ -24,12 +24,16 @@ func benchmark(dataSet string) {
 		//{"SVD++", "#SVDpp", core.NewSVDpp(nil)},
 		//{"NMF[3]", "#NMF", core.NewNMF(nil)},
 		//{"Slope One[4]", "#SlopeOne", core.NewSlopOne(nil)},
+		//{"Weighted Slope One[4]", "#NewWeightedSlopeOne", core.NewWeightedSlopeOne(nil)},
+		//{"Bi-Polar Slope One[4]", "#NewBiPolarSlopeOne", core.NewBiPolarSlopeOne(nil)},
 		//{"KNN", "#NewKNN", core.NewKNN(nil)},
 		//{"Centered k-NN", "#NewKNNWithMean", core.NewKNNWithMean(nil)},
 		//{"k-NN Baseline", "#NewKNNBaseLine", core.NewKNNBaseLine(nil)},
 		//{"k-NN Z-Score", "#NewKNNWithZScore", core.NewKNNWithZScore(nil)},
 		//{"Co-Clustering[5]", "#CoClustering", core.NewCoClustering(nil)},
+		{"AutoRec[8]", "#AutoRec", core.NewAutoRec(nil)},
 		//{"BaseLine", "#BaseLine", core.NewBaseLine(nil)},
+		//{"Ensemble", "#Ensemble", core.NewEnsemble(nil, core.NewSVD(nil), core.NewKNNBaseLine(nil), core.NewSlopOne(nil))},
 		{"Random", "#Random", core.NewRandom(nil)},
 	}
 	set := core.LoadDataFromBuiltIn(dataSet)
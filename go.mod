@@ -1,3 +1,5 @@
 module github.com/zhenghaoz/gorse
 
 require gonum.org/v1/gonum v0.0.0-20181107204152-48288cca5b5e
+
+require golang.org/x/exp v0.0.0-20180321215751-8460e604b9de